@@ -1,6 +1,8 @@
 package main
 
 import (
+	"m6kparse/discovery"
+
 	"github.com/quarkslab/wirego/wirego_remote/go/wirego"
 )
 
@@ -12,34 +14,27 @@ func (wgo *WiregoM6k) DissectPacketUDP(packetNumber int, src string, dst string,
 	res.Protocol = "TC Discovery"
 
 	//Check magic 0x12345678
-	if (len(packet) >= 4) && (packet[0] != 0x12 || packet[1] != 0x34 || packet[2] != 0x56 || packet[3] != 0x78) {
+	if !discovery.IsDiscovery(packet) {
 		res.Info = "not identified (no magic)"
 		return &res
 	}
-	res.Fields = append(res.Fields, wirego.DissectField{WiregoFieldId: FieldIdDiscoveryMagic, Offset: 0, Length: 4})
+	res.Fields = append(res.Fields, wirego.DissectField{WiregoFieldId: FieldIdDiscoveryMagic, Offset: 0, Length: discovery.MagicSize})
 
-	//Identify peers (use the icon probe)
-	if !wgo.iconIdentified && len(packet) > 10 && (string(packet[4:10]) == "TCIcon") {
-		wgo.iconIdentified = true
-		wgo.iconIP = src
-		wgo.frameIP = ""
-	}
-	if !wgo.iconIdentified {
+	//Identify peers (use the icon probe and the frame responses)
+	wgo.roles.ObserveDiscovery(src, dst, packet)
+	iconIP := wgo.roles.IconIP()
+	if iconIP == "" {
 		res.Info = "icon not identified"
 		return &res
 	}
 
 	//Icon to frame message
-	if src == wgo.iconIP {
+	if src == iconIP {
 		parseDiscoveryIconToFrame(packet, &res)
 	}
 
 	//Frame to icon message
-	if dst == wgo.iconIP {
-		//If not already known, identify responding frame
-		if wgo.frameIP == "" {
-			wgo.frameIP = src
-		}
+	if dst == iconIP {
 		parseDiscoveryFrameToIcon(packet, &res)
 	}
 
@@ -47,45 +42,30 @@ func (wgo *WiregoM6k) DissectPacketUDP(packetNumber int, src string, dst string,
 }
 
 func parseDiscoveryIconToFrame(packet []byte, result *wirego.DissectResult) error {
-	result.Info = "Icon probe"
+	m, err := discovery.Decode(packet)
+	if err != nil {
+		result.Info = "Malformed Icon message: " + err.Error()
+		return err
+	}
+	result.Info = m.String()
 
 	return nil
 }
 
 func parseDiscoveryFrameToIcon(packet []byte, result *wirego.DissectResult) error {
-	result.Info = "Mainframe probe response"
-	result.Fields = append(result.Fields, wirego.DissectField{WiregoFieldId: FieldIdFrameSerial, Offset: 4, Length: 4})
-
-	result.Fields = append(result.Fields, wirego.DissectField{WiregoFieldId: FieldIdMessagesCount, Offset: 8, Length: 1})
-	result.Fields = append(result.Fields, wirego.DissectField{WiregoFieldId: FieldIdMessagesNumber, Offset: 9, Length: 1})
-
-	//File name -> look for trailing \0
-	idxStart := 0x14
-	length := 0
-	for {
-		if idxStart+length >= len(packet) {
-			break
-		}
-		if packet[idxStart+length] == 0x00 {
-			break
-		}
-		length++
+	r, err := discovery.DecodeFrameResponse(packet)
+	if err != nil {
+		result.Info = "Malformed Mainframe probe response: " + err.Error()
+		return err
 	}
-	result.Fields = append(result.Fields, wirego.DissectField{WiregoFieldId: FieldIdFileName, Offset: idxStart, Length: length})
+	result.Info = r.String()
+	result.Fields = append(result.Fields, wirego.DissectField{WiregoFieldId: FieldIdFrameSerial, Offset: discovery.SerialOffset, Length: 4})
+	result.Fields = append(result.Fields, wirego.DissectField{WiregoFieldId: FieldIdMessagesCount, Offset: discovery.CountOffset, Length: 1})
+	result.Fields = append(result.Fields, wirego.DissectField{WiregoFieldId: FieldIdMessagesNumber, Offset: discovery.IndexOffset, Length: 1})
 
-	//Frame name -> look for trailing \0
-	idxStart = 0x54
-	length = 0
-	for {
-		if idxStart+length >= len(packet) {
-			break
-		}
-		if packet[idxStart+length] == 0x00 {
-			break
-		}
-		length++
-	}
-	result.Fields = append(result.Fields, wirego.DissectField{WiregoFieldId: FieldIdFrameName, Offset: idxStart, Length: length})
+	//Names are null terminated, unless they fill their field
+	result.Fields = append(result.Fields, wirego.DissectField{WiregoFieldId: FieldIdFileName, Offset: discovery.EntryOffset, Length: len(r.EntryName)})
+	result.Fields = append(result.Fields, wirego.DissectField{WiregoFieldId: FieldIdFrameName, Offset: discovery.DeviceOffset, Length: len(r.DeviceName)})
 
 	return nil
 }
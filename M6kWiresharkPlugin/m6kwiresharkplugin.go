@@ -1,11 +1,12 @@
 package main
 
 import (
-	"encoding/hex"
 	"fmt"
 	"log"
 	"m6kparse/common"
+	"m6kparse/decoder"
 	"m6kparse/m6000parser"
+	"m6kparse/roles"
 	"os"
 	"strings"
 
@@ -30,9 +31,7 @@ const (
 
 // Since we implement the wirego.WiregoInterface we need some structure to hold it.
 type WiregoM6k struct {
-	iconIdentified bool
-	iconIP         string
-	frameIP        string
+	roles *roles.Detector
 
 	iconToFrameParser *m6000parser.M6000Parser
 	frameToIconParser *m6000parser.M6000Parser
@@ -42,13 +41,16 @@ type WiregoM6k struct {
 // Unused (but mandatory)
 func main() {
 	var wgo WiregoM6k
-	wgo.iconIdentified = false
+	wgo.roles = roles.New("", "")
 	wgo.log = log.New(os.Stdout, "Wirego> ", 0)
 	wgo.log.Println("m6000 ready")
 	wgo.iconToFrameParser = m6000parser.New(wgo.log, common.IconToFrame)
 	wgo.frameToIconParser = m6000parser.New(wgo.log, common.FrameToIcon)
+	wgo.roles.AddHandler(func(r roles.Role) {
+		wgo.log.Println(r.String())
+	})
 
-	wg, err := wirego.New("ipc:///tmp/wirego0", false, wgo)
+	wg, err := wirego.New("ipc:///tmp/wirego0", false, &wgo)
 	if err != nil {
 		fmt.Println(err)
 		return
@@ -59,7 +61,7 @@ func main() {
 }
 
 // This function is called when the plugin is loaded.
-func (wgo WiregoM6k) Setup() error {
+func (wgo *WiregoM6k) Setup() error {
 
 	//Setup our wireshark custom fields
 	fields = append(fields, wirego.WiresharkField{WiregoFieldId: FieldIdDiscoveryMagic, Name: "Discovery magic", Filter: "tcm6000.magic", ValueType: wirego.ValueTypeUInt32, DisplayMode: wirego.DisplayModeHexadecimal})
@@ -75,25 +77,25 @@ func (wgo WiregoM6k) Setup() error {
 }
 
 // This function shall return the plugin name
-func (wgo WiregoM6k) GetName() string {
+func (wgo *WiregoM6k) GetName() string {
 	return "TC M6000"
 }
 
 // This function shall return the wireshark filter
-func (wgo WiregoM6k) GetFilter() string {
+func (wgo *WiregoM6k) GetFilter() string {
 	return "tcm6000"
 }
 
 // GetFields returns the list of fields descriptor that we may eventually return
 // when dissecting a packet payload
-func (wgo WiregoM6k) GetFields() []wirego.WiresharkField {
+func (wgo *WiregoM6k) GetFields() []wirego.WiresharkField {
 	return fields
 }
 
 // GetDetectionFilters returns a wireshark filter that will select which packets
 // will be sent to your dissector for parsing.
 // Two types of filters can be defined: Integers or Strings
-func (wgo WiregoM6k) GetDetectionFilters() []wirego.DetectionFilter {
+func (wgo *WiregoM6k) GetDetectionFilters() []wirego.DetectionFilter {
 	var filters []wirego.DetectionFilter
 
 	filters = append(filters, wirego.DetectionFilter{FilterType: wirego.DetectionFilterTypeInt, Name: "udp.port", ValueInt: 17})
@@ -102,15 +104,15 @@ func (wgo WiregoM6k) GetDetectionFilters() []wirego.DetectionFilter {
 	return filters
 }
 
-func (wgo WiregoM6k) GetDetectionHeuristicsParents() []string {
+func (wgo *WiregoM6k) GetDetectionHeuristicsParents() []string {
 	return []string{}
 }
 
-func (wgo WiregoM6k) DetectionHeuristic(packetNumber int, src string, dst string, layer string, packet []byte) bool {
+func (wgo *WiregoM6k) DetectionHeuristic(packetNumber int, src string, dst string, layer string, packet []byte) bool {
 	return false
 }
 
-func (wgo WiregoM6k) DissectPacket(packetNumber int, src string, dst string, layer string, packet []byte) *wirego.DissectResult {
+func (wgo *WiregoM6k) DissectPacket(packetNumber int, src string, dst string, layer string, packet []byte) *wirego.DissectResult {
 	if layer == "frame.eth.ethertype.ip.tcp.tcm6000" {
 		return wgo.DissectPacketTCP(packetNumber, src, dst, layer, packet)
 	} else if layer == "frame.eth.ethertype.ip.udp.tcm6000" {
@@ -123,56 +125,33 @@ func (wgo WiregoM6k) DissectPacket(packetNumber int, src string, dst string, lay
 }
 
 // DissectPacket provides the packet payload to be parsed.
-func (wgo WiregoM6k) DissectPacketTCP(packetNumber int, src string, dst string, layer string, packet []byte) *wirego.DissectResult {
+func (wgo *WiregoM6k) DissectPacketTCP(packetNumber int, src string, dst string, layer string, packet []byte) *wirego.DissectResult {
 	var res wirego.DissectResult
 
 	//This string will appear on the packet being parsed
 	res.Protocol = "TC Proto"
 
-	if !wgo.iconIdentified {
-		wgo.iconIdentified = true
-
-		if src == "192.168.1.249" {
-			wgo.frameIP = src
-			wgo.iconIP = dst
-		} else if dst == "192.168.1.249" {
-			wgo.frameIP = dst
-			wgo.iconIP = src
-		} else {
-			wgo.frameIP = src
-			wgo.iconIP = dst
+	//No TCP ports here, use the direction of the SysEx message types.
+	//Direction is not known yet, each packet is decoded on its own.
+	if !wgo.roles.Icon().Known() || !wgo.roles.Frame().Known() {
+		for _, msg := range decoder.New().Push(common.IconToFrame, packet).Messages {
+			if msg.Type == decoder.MessageSysEx {
+				wgo.roles.ObserveSysEx(src, dst, msg.Data)
+			}
 		}
-		//res.Info = "Icon not identified, cannot parse"
-		//return &res
+	}
+	if !wgo.roles.IsIconFrame(src, dst) {
+		res.Info = "Icon not identified, cannot parse"
+		return &res
 	}
 
-	//	res.Fields = append(res.Fields, wirego.DissectField{WiregoFieldId: FieldIdProtoVersion, Offset: 0, Length: 2})
-	//	res.Fields = append(res.Fields, wirego.DissectField{WiregoFieldId: FieldIdBlockSize, Offset: 2, Length: 2})
-
-	//	fmt.Println(hex.Dump(packet))
 	var parserResult m6000parser.Result
 
-	if src == wgo.iconIP {
-		parserResult = wgo.iconToFrameParser.PushPacket(packetNumber, packet)
+	if src == wgo.roles.IconIP() {
+		parserResult = wgo.iconToFrameParser.PushPacket(packet)
 	} else {
-		parserResult = wgo.frameToIconParser.PushPacket(packetNumber, packet)
-	}
-
-	var tmp []byte
-	fmt.Println("A")
-	for i := 0; i < len(packet)-1; i += 2 {
-		a := packet[i]
-		b := packet[i+1]
-		tmp = append(tmp, (a<<4)|(b&0x0F))
-	}
-	fmt.Println(hex.Dump(tmp))
-	fmt.Println("B")
-	for i := 1; i < len(packet)-1; i += 2 {
-		a := packet[i]
-		b := packet[i+1]
-		tmp = append(tmp, (a<<4)|(b&0x0F))
+		parserResult = wgo.frameToIconParser.PushPacket(packet)
 	}
-	fmt.Println(hex.Dump(tmp))
 
 	aggregate := strings.Join(parserResult.Description, "|")
 	switch parserResult.Status {
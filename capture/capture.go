@@ -2,7 +2,15 @@ package capture
 
 import (
 	"log"
+	"m6kparse/catalog"
+	"m6kparse/correlator"
+	"m6kparse/discovery"
+	"m6kparse/meter"
+	"m6kparse/roles"
+	"m6kparse/state"
+	"m6kparse/sysex"
 	"m6kparse/tcpparser"
+	"m6kparse/timecode"
 	"m6kparse/udpparser"
 	"time"
 
@@ -15,18 +23,71 @@ type Capture struct {
 	logs      *log.Logger
 	udpParser *udpparser.UDPParser
 	tcpParser *tcpparser.TCPParser
+	state     *state.Mirror
+	roles     *roles.Detector
 }
 
+// New creates a Capture, Icon and Frame addresses are detected from the traffic if empty or "auto"
 func New(logs *log.Logger, iconIP string, frameIP string) *Capture {
 	var cap Capture
 
 	cap.logs = logs
-	cap.udpParser = udpparser.New(iconIP, frameIP, cap.logs)
-	cap.tcpParser = tcpparser.New(iconIP, frameIP, cap.logs)
+	cap.roles = roles.New(iconIP, frameIP)
+	cap.roles.AddHandler(func(r roles.Role) {
+		cap.logs.Println("[Roles] " + r.String())
+	})
+	cap.udpParser = udpparser.New(cap.roles, cap.logs)
+	cap.tcpParser = tcpparser.New(cap.roles, cap.logs)
+	cap.state = state.New()
+	cap.tcpParser.AddHandler(func(ev tcpparser.Event) {
+		if data, ok := ev.SysEx.(*sysex.ParamData); ok {
+			cap.state.Apply(ev.Session, ev.Timestamp, data)
+		}
+	})
 
 	return &cap
 }
 
+// SetCatalog sets the parameters catalog used to print ParamData values
+func (cap *Capture) SetCatalog(c *catalog.Catalog) {
+	cap.tcpParser.SetCatalog(c)
+}
+
+// AddTCPHandler registers a function called for each event found in the Icon/Frame TCP stream
+func (cap *Capture) AddTCPHandler(h tcpparser.Handler) {
+	cap.tcpParser.AddHandler(h)
+}
+
+// Correlator returns the requests and responses pairing of the Icon/Frame TCP stream
+func (cap *Capture) Correlator() *correlator.Correlator {
+	return cap.tcpParser.Correlator()
+}
+
+// Discovery returns the Mainframes found in the UDP discovery traffic
+func (cap *Capture) Discovery() *discovery.Tracker {
+	return cap.udpParser.Tracker()
+}
+
+// Timecodes returns the decoder of the UDP timecode streams
+func (cap *Capture) Timecodes() *timecode.Decoder {
+	return cap.udpParser.Timecodes()
+}
+
+// Meters returns the decoder of the UDP meter streams
+func (cap *Capture) Meters() *meter.Decoder {
+	return cap.udpParser.Meters()
+}
+
+// Roles returns the Icon and Frame addresses detection
+func (cap *Capture) Roles() *roles.Detector {
+	return cap.roles
+}
+
+// State returns the mirror of the Frame parameters seen in the Icon/Frame TCP stream
+func (cap *Capture) State() *state.Mirror {
+	return cap.state
+}
+
 func (cap *Capture) ReadLive(networkInterface string) error {
 
 	h, err := pcap.OpenLive(networkInterface, 1500, true, 1*time.Millisecond)
@@ -51,6 +112,7 @@ func (cap *Capture) capturePackets(h *pcap.Handle) error {
 
 	packetSource := gopacket.NewPacketSource(h, h.LinkType())
 	for packet := range packetSource.Packets() {
+		cap.roles.Observe(packet)
 		ipLayer := packet.Layer(layers.LayerTypeIPv4)
 
 		if ipLayer != nil {
@@ -74,5 +136,7 @@ func (cap *Capture) capturePackets(h *pcap.Handle) error {
 			}
 		}
 	}
+	cap.tcpParser.Flush()
+	cap.udpParser.Flush()
 	return nil
 }
@@ -0,0 +1,235 @@
+package catalog
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"m6kparse/codec"
+	"m6kparse/sysex"
+	"os"
+	"strconv"
+)
+
+/*
+The catalog maps engine/param addresses to parameter descriptions:
+
+	{
+	  "parameters": [
+	    {"engine": 6, "param": 127, "name": "Device name", "type": "string", "length": 32},
+	    {"engine": 1, "param": 4, "name": "Level", "type": "int", "min": -100, "max": 0, "unit": "dB"},
+	    {"engine": 1, "param": 5, "name": "Mode", "type": "enum", "enum": {"0": "Off", "1": "On"}}
+	  ]
+	}
+
+"unknown" can be set to match the unknown field of the requests (defaults to 0).
+*/
+
+type ValueType string
+
+const (
+	TypeUInt   ValueType = "uint"   // Unsigned 14 bits value
+	TypeInt    ValueType = "int"    // Signed 14 bits value
+	TypeBool   ValueType = "bool"   // 0 or 1
+	TypeEnum   ValueType = "enum"   // Named values
+	TypeChar   ValueType = "char"   // Single character
+	TypeString ValueType = "string" // One character per parameter, over "length" parameters
+)
+
+//go:embed m6000.json
+var defaultCatalog []byte
+
+// Param describes a parameter, or a range of parameters for strings
+type Param struct {
+	Engine  byte              `json:"engine"`
+	Param   int               `json:"param"`
+	Unknown uint16            `json:"unknown"`
+	Name    string            `json:"name"`
+	Type    ValueType         `json:"type"`
+	Length  int               `json:"length,omitempty"`
+	Min     *int              `json:"min,omitempty"`
+	Max     *int              `json:"max,omitempty"`
+	Unit    string            `json:"unit,omitempty"`
+	Scale   float64           `json:"scale,omitempty"`
+	Enum    map[string]string `json:"enum,omitempty"`
+}
+
+type catalogFile struct {
+	Parameters []*Param `json:"parameters"`
+}
+
+type Catalog struct {
+	params map[sysex.ParamAddress]*Param
+}
+
+// Value is a decoded parameter value
+type Value struct {
+	Address    sysex.ParamAddress
+	Param      *Param // nil if the parameter is not in the catalog
+	Raw        []uint16
+	Text       string
+	OutOfRange bool
+}
+
+// Default returns the catalog shipped with the parser
+func Default() *Catalog {
+	c, err := Parse(defaultCatalog)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+// Load reads a catalog file
+func Load(path string) (*Catalog, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+// Parse decodes a JSON catalog
+func Parse(data []byte) (*Catalog, error) {
+	var f catalogFile
+	var c Catalog
+
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, err
+	}
+
+	c.params = make(map[sysex.ParamAddress]*Param)
+	for _, p := range f.Parameters {
+		if err := p.check(); err != nil {
+			return nil, err
+		}
+		for i := 0; i < p.count(); i++ {
+			addr := sysex.ParamAddress{Engine: p.Engine, Param: p.Param + i, Unknown: p.Unknown}
+			if _, found := c.params[addr]; found {
+				return nil, fmt.Errorf("catalog: %s defined twice", addr)
+			}
+			c.params[addr] = p
+		}
+	}
+	return &c, nil
+}
+
+// Lookup returns the parameter found at an address
+func (c *Catalog) Lookup(addr sysex.ParamAddress) (*Param, bool) {
+	p, found := c.params[addr]
+	return p, found
+}
+
+// Describe decodes the values of a ParamData response.
+// Strings are returned as a single value when they are complete.
+func (c *Catalog) Describe(m *sysex.ParamData) []Value {
+	var values []Value
+
+	for i := 0; i < len(m.Values); i++ {
+		addr := m.Address(i)
+		p, found := c.params[addr]
+
+		//Complete string
+		if found && p.Type == TypeString && addr.Param == p.Param && i+p.Length <= len(m.Values) {
+			raw := m.Values[i : i+p.Length]
+			values = append(values, Value{Address: addr, Param: p, Raw: raw, Text: p.formatString(raw)})
+			i += p.Length - 1
+			continue
+		}
+
+		v := Value{Address: addr, Raw: m.Values[i : i+1]}
+		if found {
+			v.Param = p
+			v.Text, v.OutOfRange = p.Format(m.Values[i])
+		} else {
+			v.Text = fmt.Sprintf("0x%04x", m.Values[i])
+		}
+		values = append(values, v)
+	}
+	return values
+}
+
+// Known tells if the value's parameter is in the catalog
+func (v Value) Known() bool {
+	return v.Param != nil
+}
+
+func (v Value) String() string {
+	if v.Param == nil {
+		return fmt.Sprintf("[unknown] %s = %s", v.Address, v.Text)
+	}
+	str := fmt.Sprintf("%s (%s) = %s", v.Param.Name, v.Address, v.Text)
+	if v.OutOfRange {
+		str += " [out of range]"
+	}
+	return str
+}
+
+// Format returns a single value formatted according to the parameter type
+func (p *Param) Format(raw uint16) (string, bool) {
+	var str string
+
+	value := int(raw)
+	switch p.Type {
+	case TypeInt:
+		value = int(codec.DecodeSigned14(codec.Encode14(raw)))
+	case TypeBool:
+		if raw == 0 {
+			return "Off", false
+		}
+		return "On", raw != 1
+	case TypeEnum:
+		name, found := p.Enum[strconv.Itoa(value)]
+		if !found {
+			return fmt.Sprintf("%d", value), true
+		}
+		return name, false
+	case TypeChar, TypeString:
+		if !strconv.IsPrint(rune(raw)) {
+			return fmt.Sprintf("0x%02x", raw), raw != 0
+		}
+		return string(rune(raw)), false
+	}
+
+	if p.Scale != 0 {
+		str = strconv.FormatFloat(float64(value)*p.Scale, 'f', -1, 64)
+	} else {
+		str = strconv.Itoa(value)
+	}
+	if p.Unit != "" {
+		str += " " + p.Unit
+	}
+
+	outOfRange := (p.Min != nil && value < *p.Min) || (p.Max != nil && value > *p.Max)
+	return str, outOfRange
+}
+
+func (p *Param) formatString(raw []uint16) string {
+	var str []rune
+	for _, c := range raw {
+		if c == 0 {
+			break
+		}
+		str = append(str, rune(c))
+	}
+	return strconv.Quote(string(str))
+}
+
+func (p *Param) count() int {
+	if p.Type == TypeString {
+		return p.Length
+	}
+	return 1
+}
+
+func (p *Param) check() error {
+	switch p.Type {
+	case TypeUInt, TypeInt, TypeBool, TypeEnum, TypeChar:
+	case TypeString:
+		if p.Length <= 0 {
+			return fmt.Errorf("catalog: %s string without length", p.Name)
+		}
+	default:
+		return fmt.Errorf("catalog: %s has an invalid type '%s'", p.Name, p.Type)
+	}
+	return nil
+}
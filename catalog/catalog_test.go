@@ -0,0 +1,154 @@
+package catalog
+
+import (
+	"m6kparse/sysex"
+	"strings"
+	"testing"
+)
+
+func text(s string, length int) []uint16 {
+	values := make([]uint16, length)
+	for i, c := range []byte(s) {
+		values[i] = uint16(c)
+	}
+	return values
+}
+
+func TestDefault(t *testing.T) {
+	c := Default()
+	tests := []struct {
+		param int
+		found bool
+	}{
+		{126, false},
+		{127, true},
+		{140, true},
+		{158, true}, //Last character of the 32 bytes name
+		{159, false},
+	}
+	for _, tt := range tests {
+		p, found := c.Lookup(sysex.ParamAddress{Engine: 6, Param: tt.param})
+		if found != tt.found {
+			t.Errorf("engine 6 param %d: found %v", tt.param, found)
+			continue
+		}
+		if found && (p.Name != "Device name" || p.Type != TypeString || p.Param != 127 || p.Length != 32) {
+			t.Errorf("engine 6 param %d: unexpected parameter %+v", tt.param, p)
+		}
+	}
+	if _, found := c.Lookup(sysex.ParamAddress{Engine: 6, Param: 127, Unknown: 1}); found {
+		t.Error("found with another unknown field")
+	}
+}
+
+func TestDescribeString(t *testing.T) {
+	c := Default()
+
+	//Complete name
+	values := c.Describe(&sysex.ParamData{Engine: 6, Param: 127, Values: text("TC Electronic S6000", 32)})
+	if len(values) != 1 {
+		t.Fatalf("%d values, expected the name only: %v", len(values), values)
+	}
+	if v := values[0]; !v.Known() || v.Text != `"TC Electronic S6000"` || len(v.Raw) != 32 || v.Address.Param != 127 {
+		t.Fatalf("unexpected value %s", v)
+	}
+
+	//Truncated or not starting at the first character, one value per character
+	values = c.Describe(&sysex.ParamData{Engine: 6, Param: 127, Values: text("TC", 4)})
+	if len(values) != 4 || values[0].Text != "T" || values[1].Text != "C" || values[2].Text != "0x00" || values[2].OutOfRange {
+		t.Fatalf("unexpected values %v", values)
+	}
+	values = c.Describe(&sysex.ParamData{Engine: 6, Param: 128, Values: text("C\x01", 2)})
+	if len(values) != 2 || values[0].Text != "C" || values[1].Text != "0x01" || !values[1].OutOfRange {
+		t.Fatalf("unexpected values %v", values)
+	}
+}
+
+func TestDescribe(t *testing.T) {
+	c, err := Parse([]byte(`{"parameters": [
+		{"engine": 1, "param": 4, "name": "Level", "type": "int", "min": -100, "max": 0, "unit": "dB"},
+		{"engine": 1, "param": 6, "name": "Name", "type": "string", "length": 2}
+	]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	values := c.Describe(&sysex.ParamData{Engine: 1, Param: 3, Values: []uint16{0x10, 0x3FF6, 0x20, 'A', 'B', 0x30}})
+	expected := []string{
+		"[unknown] Eng 1 - Param 3 [unk: x0000] = 0x0010",
+		"Level (Eng 1 - Param 4 [unk: x0000]) = -10 dB",
+		"[unknown] Eng 1 - Param 5 [unk: x0000] = 0x0020",
+		`Name (Eng 1 - Param 6 [unk: x0000]) = "AB"`,
+		"[unknown] Eng 1 - Param 8 [unk: x0000] = 0x0030",
+	}
+	if len(values) != len(expected) {
+		t.Fatalf("%d values, expected %d: %v", len(values), len(expected), values)
+	}
+	for i, v := range values {
+		if v.String() != expected[i] {
+			t.Errorf("value %d: %s, expected %s", i, v, expected[i])
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	minimum, maximum := -100, 0
+	tests := []struct {
+		param      Param
+		raw        uint16
+		text       string
+		outOfRange bool
+	}{
+		{Param{Type: TypeUInt}, 1000, "1000", false},
+		{Param{Type: TypeUInt, Scale: 0.5, Unit: "ms"}, 3, "1.5 ms", false},
+		{Param{Type: TypeInt, Unit: "dB", Min: &minimum, Max: &maximum}, 0x3FF6, "-10 dB", false},
+		{Param{Type: TypeInt, Unit: "dB", Min: &minimum, Max: &maximum}, 0x3F00, "-256 dB", true},
+		{Param{Type: TypeInt, Unit: "dB", Min: &minimum, Max: &maximum}, 1, "1 dB", true},
+		{Param{Type: TypeBool}, 0, "Off", false},
+		{Param{Type: TypeBool}, 1, "On", false},
+		{Param{Type: TypeBool}, 2, "On", true},
+		{Param{Type: TypeEnum, Enum: map[string]string{"0": "Off", "1": "On"}}, 1, "On", false},
+		{Param{Type: TypeEnum, Enum: map[string]string{"0": "Off", "1": "On"}}, 5, "5", true},
+		{Param{Type: TypeChar}, 'x', "x", false},
+		{Param{Type: TypeChar}, 0, "0x00", false},
+		{Param{Type: TypeChar}, 0x7F, "0x7f", true},
+	}
+	for _, tt := range tests {
+		text, outOfRange := tt.param.Format(tt.raw)
+		if text != tt.text || outOfRange != tt.outOfRange {
+			t.Errorf("%s 0x%04x: %q (out of range %v), expected %q (%v)", tt.param.Type, tt.raw, text, outOfRange, tt.text, tt.outOfRange)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		json string
+		err  string
+	}{
+		{"malformed", `{"parameters": [{"engine": 1,}]}`, "invalid character"},
+		{"not a list", `{"parameters": {"engine": 1}}`, "cannot unmarshal"},
+		{"engine out of range", `{"parameters": [{"engine": 256, "param": 1, "name": "A", "type": "int"}]}`, "cannot unmarshal"},
+		{"invalid type", `{"parameters": [{"engine": 1, "param": 1, "name": "A", "type": "float"}]}`, "invalid type"},
+		{"string without length", `{"parameters": [{"engine": 1, "param": 1, "name": "A", "type": "string"}]}`, "without length"},
+		{"defined twice", `{"parameters": [
+			{"engine": 1, "param": 1, "name": "A", "type": "string", "length": 4},
+			{"engine": 1, "param": 3, "name": "B", "type": "uint"}
+		]}`, "defined twice"},
+	}
+	for _, tt := range tests {
+		_, err := Parse([]byte(tt.json))
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: error %v, expected %q", tt.name, err, tt.err)
+		}
+	}
+
+	//Same param with another unknown field
+	if _, err := Parse([]byte(`{"parameters": [
+		{"engine": 1, "param": 1, "name": "A", "type": "uint"},
+		{"engine": 1, "param": 1, "unknown": 36, "name": "B", "type": "uint"}
+	]}`)); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,112 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"m6kparse/common"
+	"m6kparse/decoder"
+	"m6kparse/sysex"
+	"net"
+	"os"
+	"strconv"
+	"time"
+)
+
+const (
+	m6000Port = 1026
+	readSize  = 4096
+)
+
+var ErrNoResponse = errors.New("no response from the Frame")
+
+// Client talks to a Frame over the TCP port 1026, as the Icon does.
+// What the Icon sends when it connects is not replayed, the Frame accepting
+// messages from a fresh connection is not checked against a capture.
+type Client struct {
+	logs    *log.Logger
+	conn    net.Conn
+	decoder *decoder.Decoder
+}
+
+// Dial connects to a Frame
+func Dial(frameIP string, timeout time.Duration, logs *log.Logger) (*Client, error) {
+	return dial(net.JoinHostPort(frameIP, strconv.Itoa(m6000Port)), timeout, logs)
+}
+
+func dial(address string, timeout time.Duration, logs *log.Logger) (*Client, error) {
+	var c Client
+
+	conn, err := net.DialTimeout("tcp", address, timeout)
+	if err != nil {
+		return nil, err
+	}
+	c.logs = logs
+	c.conn = conn
+	c.decoder = decoder.New()
+	return &c, nil
+}
+
+// Send sends a SysEx message to the Frame.
+// How the Icon splits large messages over blocks is not known, a message is sent
+// in as few blocks as the block size field allows.
+func (c *Client) Send(m sysex.Message) error {
+	data := sysex.Marshal(m)
+	c.logs.Printf("[Client] Sending %s (%d bytes)", m.String(), len(data))
+	_, err := c.conn.Write(decoder.EncodeBlocks(data, decoder.MaxBlockData))
+	return err
+}
+
+// Request sends a request and returns the first response of the matching type
+// received before nothing is received for timeout
+func (c *Client) Request(m sysex.Message, timeout time.Duration) (sysex.Message, error) {
+	info, found := sysex.Lookup(m.Type())
+	if !found || !info.Request {
+		return nil, fmt.Errorf("%s is not a request", m.String())
+	}
+	if err := c.Send(m); err != nil {
+		return nil, err
+	}
+
+	replies, err := c.Receive(timeout)
+	for _, reply := range replies {
+		if reply.Type != decoder.MessageSysEx {
+			continue
+		}
+		if r, err := sysex.Unmarshal(reply.Data); err == nil && r.Type() == info.Pair {
+			return r, nil
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return nil, fmt.Errorf("%w to %s", ErrNoResponse, m.String())
+}
+
+// Receive returns the messages sent by the Frame until nothing is received for timeout
+func (c *Client) Receive(timeout time.Duration) ([]decoder.Message, error) {
+	var messages []decoder.Message
+
+	buf := make([]byte, readSize)
+	for {
+		c.conn.SetReadDeadline(time.Now().Add(timeout))
+		n, err := c.conn.Read(buf)
+		if n != 0 {
+			res := c.decoder.Push(common.FrameToIcon, buf[:n])
+			if res.Invalid {
+				c.logs.Println("[Client] Invalid block received")
+			}
+			messages = append(messages, res.Messages...)
+		}
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			return messages, nil
+		}
+		if err != nil {
+			return messages, err
+		}
+	}
+}
+
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
@@ -0,0 +1,101 @@
+package client
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"log"
+	"m6kparse/common"
+	"m6kparse/decoder"
+	"m6kparse/sysex"
+	"net"
+	"testing"
+	"time"
+)
+
+// fakeFrame stores the PresetData received and answers the PresetRequest with them
+func fakeFrame(t *testing.T, answer bool) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		presets := make(map[uint16]*sysex.PresetData)
+		d := decoder.New()
+		buf := make([]byte, readSize)
+		for {
+			n, err := conn.Read(buf)
+			if err != nil {
+				return
+			}
+			for _, msg := range d.Push(common.IconToFrame, buf[:n]).Messages {
+				m, err := sysex.Unmarshal(msg.Data)
+				if err != nil {
+					continue
+				}
+				switch m := m.(type) {
+				case *sysex.PresetData:
+					presets[m.Number] = m
+				case *sysex.PresetRequest:
+					if p, found := presets[m.Number]; found && answer {
+						conn.Write(decoder.EncodeBlocks(sysex.Marshal(p), decoder.MaxBlockData))
+					}
+				}
+			}
+		}
+	}()
+	return l.Addr().String()
+}
+
+func TestRequest(t *testing.T) {
+	const timeout = 200 * time.Millisecond
+	logs := log.New(io.Discard, "", 0)
+	preset := &sysex.PresetData{Header: sysex.Header{Profile: sysex.ProfileM6000}, Number: 12, Data: bytes.Repeat([]byte{0x01, 0x7F}, 400)}
+	request := &sysex.PresetRequest{Header: preset.Header, Number: 12, Extra: make([]byte, 3)}
+
+	c, err := dial(fakeFrame(t, true), timeout, logs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if err := c.Send(preset); err != nil {
+		t.Fatal(err)
+	}
+	reply, err := c.Request(request, timeout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, ok := reply.(*sysex.PresetData)
+	if !ok || data.Number != preset.Number || !bytes.Equal(data.Data, preset.Data) {
+		t.Fatalf("unexpected reply %s", reply)
+	}
+
+	//Not a request
+	if _, err := c.Request(preset, timeout); err == nil {
+		t.Fatal("PresetData sent as a request")
+	}
+}
+
+func TestRequestNoResponse(t *testing.T) {
+	const timeout = 100 * time.Millisecond
+	logs := log.New(io.Discard, "", 0)
+
+	c, err := dial(fakeFrame(t, false), timeout, logs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	_, err = c.Request(&sysex.PresetRequest{Header: sysex.Header{Profile: sysex.ProfileM6000}, Number: 12, Extra: make([]byte, 3)}, timeout)
+	if !errors.Is(err, ErrNoResponse) {
+		t.Fatalf("error %v, expected ErrNoResponse", err)
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"m6kparse/capture"
+	"m6kparse/catalog"
+	"m6kparse/state"
+	"m6kparse/sysex"
+	"os"
+)
+
+func help() {
+	fmt.Println("Usage:", os.Args[0], " <Icon IP> <Mainframe IP> <before pcap> <after pcap> [-catalog <catalog file>]")
+	fmt.Println("")
+	fmt.Println("Reports the parameters whose last value seen differs between the two captures")
+	fmt.Println("")
+	fmt.Println("-catalog loads a JSON engine/parameters catalog (default is the built-in M6000 catalog)")
+	fmt.Println("")
+	fmt.Println("Icon and Mainframe IPs can be set to auto to detect them from the traffic")
+	fmt.Println("")
+	fmt.Println("Example:")
+	fmt.Println("", os.Args[0], "192.168.1.125 192.168.1.126 /tmp/before.pcap /tmp/after.pcap")
+}
+
+func main() {
+	if len(os.Args) != 5 && !(len(os.Args) == 7 && os.Args[5] == "-catalog") {
+		help()
+		return
+	}
+	iconIP := os.Args[1]
+	frameIP := os.Args[2]
+
+	f, _ := os.Create("output.log")
+	logs := log.New(f, "M6kDiff", log.Lshortfile)
+	defer f.Close()
+
+	c := catalog.Default()
+	if len(os.Args) == 7 {
+		var err error
+		c, err = catalog.Load(os.Args[6])
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+	}
+
+	before, err := snapshot(logs, iconIP, frameIP, os.Args[3], c)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	after, err := snapshot(logs, iconIP, frameIP, os.Args[4], c)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	diffs := state.Diff(before, after)
+	for _, d := range diffs {
+		fmt.Printf("%-40s %-16s -> %s\n", name(c, d.Address), value(c, d.Address, d.A, d.InA), value(c, d.Address, d.B, d.InB))
+	}
+	fmt.Printf("%d parameters before, %d parameters after, %d differences\n", len(before), len(after), len(diffs))
+}
+
+// snapshot returns the last value seen for each parameter in a pcap
+func snapshot(logs *log.Logger, iconIP string, frameIP string, pcapFile string, c *catalog.Catalog) (map[sysex.ParamAddress]state.Value, error) {
+	logs.Println("Reading", pcapFile)
+	cap := capture.New(logs, iconIP, frameIP)
+	cap.SetCatalog(c)
+	if err := cap.ReadPcap(pcapFile); err != nil {
+		return nil, fmt.Errorf("%s: %w", pcapFile, err)
+	}
+	return cap.State().Snapshot(), nil
+}
+
+func name(c *catalog.Catalog, addr sysex.ParamAddress) string {
+	if p, found := c.Lookup(addr); found {
+		return fmt.Sprintf("%s (%s)", addr, p.Name)
+	}
+	return addr.String()
+}
+
+func value(c *catalog.Catalog, addr sysex.ParamAddress, v state.Value, found bool) string {
+	if !found {
+		return "(not seen)"
+	}
+	if p, known := c.Lookup(addr); known {
+		text, outOfRange := p.Format(v.Raw)
+		if outOfRange {
+			text += " [out of range]"
+		}
+		return fmt.Sprintf("%s [0x%04x]", text, v.Raw)
+	}
+	return fmt.Sprintf("0x%04x", v.Raw)
+}
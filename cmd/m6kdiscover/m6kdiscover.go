@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"m6kparse/discovery"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultSerial  = 112255
+	defaultTimeout = 2000
+)
+
+func help() {
+	fmt.Println("Usage:", os.Args[0], " <mode> [options]")
+	fmt.Println("")
+	fmt.Println("mode can be:")
+	fmt.Println(" -scan: send an Icon probe and list the Mainframes answering")
+	fmt.Println("   (responses are expected on the source port of the probe, not verified against a real Mainframe)")
+	fmt.Println(" -respond: answer the Icon probes as a Mainframe would")
+	fmt.Println("")
+	fmt.Println("-scan options:")
+	fmt.Println(" -target <ip:port>: address the probe is sent to (default 255.255.255.255:" + strconv.Itoa(discovery.Port) + ")")
+	fmt.Println(" -timeout <ms>: time waiting for responses (default " + strconv.Itoa(defaultTimeout) + ")")
+	fmt.Println("")
+	fmt.Println("-respond options:")
+	fmt.Println(" -listen <ip:port>: address to listen on (default 0.0.0.0:" + strconv.Itoa(discovery.Port) + ")")
+	fmt.Println(" -serial <number>: Mainframe serial number (default " + strconv.Itoa(defaultSerial) + ")")
+	fmt.Println(" -device <name>: Mainframe device name (default " + discovery.DefaultDeviceName + ")")
+	fmt.Println(" -entries <name,name,...>: entry names (default <serial>_" + discovery.DefaultModel + "_<" + strings.Join(discovery.DefaultServices, "|") + ">)")
+	fmt.Println("")
+	fmt.Println("Example:")
+	fmt.Println("", os.Args[0], "-scan")
+	fmt.Println("", os.Args[0], "-scan -target 127.0.0.1:1717 -timeout 500")
+	fmt.Println("", os.Args[0], "-respond -serial 112255")
+	fmt.Println("", os.Args[0], "-respond -listen 127.0.0.1:1717")
+}
+
+func main() {
+	if len(os.Args) < 2 || len(os.Args)%2 != 0 {
+		help()
+		return
+	}
+
+	logs := log.New(os.Stdout, "M6kDiscover ", log.Ltime)
+
+	options := make(map[string]string)
+	for i := 2; i < len(os.Args); i += 2 {
+		options[os.Args[i]] = os.Args[i+1]
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "-scan":
+		err = scan(logs, options)
+	case "-respond":
+		err = respond(logs, options)
+	default:
+		help()
+		return
+	}
+	if err != nil {
+		fmt.Println(err)
+	}
+}
+
+func scan(logs *log.Logger, options map[string]string) error {
+	target := "255.255.255.255:" + strconv.Itoa(discovery.Port)
+	timeout := defaultTimeout
+
+	for name, value := range options {
+		switch name {
+		case "-target":
+			target = value
+		case "-timeout":
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("invalid timeout: %w", err)
+			}
+			timeout = n
+		default:
+			return fmt.Errorf("unknown option %s", name)
+		}
+	}
+
+	frames, err := discovery.Scan(target, time.Duration(timeout)*time.Millisecond, logs)
+	if err != nil {
+		return err
+	}
+	for _, frame := range frames {
+		fmt.Printf("Serial %d | IP %s | Device %s | Services %s", frame.Serial, frame.IP, frame.DeviceName, strings.Join(frame.Services, ", "))
+		if !frame.Complete() {
+			fmt.Printf(" | Incomplete (%d/%d responses)", frame.Received, frame.Count)
+		}
+		fmt.Println()
+	}
+	fmt.Println(len(frames), "Mainframes found")
+	return nil
+}
+
+func respond(logs *log.Logger, options map[string]string) error {
+	listen := "0.0.0.0:" + strconv.Itoa(discovery.Port)
+	serial := uint32(defaultSerial)
+	device := discovery.DefaultDeviceName
+	var entries []string
+
+	for name, value := range options {
+		switch name {
+		case "-listen":
+			listen = value
+		case "-serial":
+			n, err := strconv.ParseUint(value, 10, 32)
+			if err != nil {
+				return fmt.Errorf("invalid serial number: %w", err)
+			}
+			serial = uint32(n)
+		case "-device":
+			device = value
+		case "-entries":
+			entries = strings.Split(value, ",")
+		default:
+			return fmt.Errorf("unknown option %s", name)
+		}
+	}
+	if entries == nil {
+		entries = discovery.EntryNames(serial)
+	}
+
+	r, err := discovery.Listen(listen, discovery.NewResponseSet(serial, device, entries), logs)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	logs.Printf("Answering probes on %s as %s serial %d (%s)", r.Addr(), device, serial, strings.Join(entries, ", "))
+	return r.Serve()
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"m6kparse/capture"
+	"m6kparse/preset"
+	"m6kparse/sysex"
+	"m6kparse/tcpparser"
+	"os"
+	"strconv"
+)
+
+func help() {
+	fmt.Println("Usage:")
+	fmt.Println("", os.Args[0], "-syx <syx file A> <syx file B>")
+	fmt.Println("", os.Args[0], "-pcap <Icon IP> <Mainframe IP> <pcap A> <pcap B> <preset number>")
+	fmt.Println("")
+	fmt.Println("Shows the fields and byte ranges that differ between two presets")
+	fmt.Println("In pcap mode, the last PresetData seen for the preset number is used")
+	fmt.Println("")
+	fmt.Println("Icon and Mainframe IPs can be set to auto to detect them from the traffic")
+	fmt.Println("")
+	fmt.Println("Example:")
+	fmt.Println("", os.Args[0], "-syx /tmp/presets/012-Hall.syx /tmp/presets/013-Hall_bright.syx")
+	fmt.Println("", os.Args[0], "-pcap 192.168.1.125 192.168.1.126 /tmp/before.pcap /tmp/after.pcap 12")
+}
+
+func main() {
+	var a, b *preset.Preset
+	var err error
+
+	if len(os.Args) == 4 && os.Args[1] == "-syx" {
+		_, a, err = preset.Load(os.Args[2])
+		if err == nil {
+			_, b, err = preset.Load(os.Args[3])
+		}
+	} else if len(os.Args) == 7 && os.Args[1] == "-pcap" {
+		var number uint64
+		number, err = strconv.ParseUint(os.Args[6], 10, 16)
+		if err == nil {
+			f, _ := os.Create("output.log")
+			logs := log.New(f, "M6kPresetDiff", log.Lshortfile)
+			defer f.Close()
+
+			a, err = fromPcap(logs, os.Args[2], os.Args[3], os.Args[4], uint16(number))
+			if err == nil {
+				b, err = fromPcap(logs, os.Args[2], os.Args[3], os.Args[5], uint16(number))
+			}
+		}
+	} else {
+		help()
+		return
+	}
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	fmt.Println("A:", a.String())
+	fmt.Println("B:", b.String())
+	diffs := preset.Compare(a, b)
+	for _, d := range diffs {
+		fmt.Println(d.String())
+	}
+	fmt.Println(len(diffs), "differences")
+}
+
+// fromPcap returns the last PresetData seen for a preset in a pcap
+func fromPcap(logs *log.Logger, iconIP string, frameIP string, pcapFile string, number uint16) (*preset.Preset, error) {
+	var last *sysex.PresetData
+
+	cap := capture.New(logs, iconIP, frameIP)
+	cap.AddTCPHandler(func(ev tcpparser.Event) {
+		if data, ok := ev.SysEx.(*sysex.PresetData); ok && data.Number == number {
+			last = data
+		}
+	})
+	if err := cap.ReadPcap(pcapFile); err != nil {
+		return nil, fmt.Errorf("%s: %w", pcapFile, err)
+	}
+	if last == nil {
+		return nil, fmt.Errorf("%s: preset %d not found", pcapFile, number)
+	}
+	return preset.Decode(last)
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"m6kparse/preset"
+	"m6kparse/sysex"
+	"os"
+)
+
+func help() {
+	fmt.Println("Usage:", os.Args[0], " <mode> <input file> <output file>")
+	fmt.Println("")
+	fmt.Println("mode can be:")
+	fmt.Println(" -tojson: convert a .syx preset into an editable JSON document")
+	fmt.Println(" -tosyx: convert a JSON document back into a .syx preset")
+	fmt.Println("")
+	fmt.Println("Example:")
+	fmt.Println("", os.Args[0], "-tojson /tmp/presets/012-Hall.syx /tmp/hall.json")
+	fmt.Println("", os.Args[0], "-tosyx /tmp/hall.json /tmp/presets/012-Hall_renamed.syx")
+}
+
+func main() {
+	if len(os.Args) != 4 {
+		help()
+		return
+	}
+	mode := os.Args[1]
+	input := os.Args[2]
+	output := os.Args[3]
+
+	var data []byte
+	var err error
+	switch mode {
+	case "-tojson":
+		var m *sysex.PresetData
+		m, _, err = preset.Load(input)
+		if err == nil {
+			data, err = preset.MarshalJSON(m)
+		}
+	case "-tosyx":
+		data, err = os.ReadFile(input)
+		if err == nil {
+			var m *sysex.PresetData
+			m, err = preset.UnmarshalJSON(data)
+			if err == nil {
+				data = sysex.Marshal(m)
+			}
+		}
+	default:
+		help()
+		return
+	}
+	if err != nil {
+		fmt.Println(input+":", err)
+		return
+	}
+
+	if err := os.WriteFile(output, data, 0644); err != nil {
+		fmt.Println(err)
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"m6kparse/capture"
+	"m6kparse/preset"
+	"m6kparse/sysex"
+	"m6kparse/tcpparser"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func help() {
+	fmt.Println("Usage:", os.Args[0], " <Icon IP> <Mainframe IP> <source> [<source>...]")
+	fmt.Println("")
+	fmt.Println("Aligns PresetData dumps of the same length and prints a candidate field map")
+	fmt.Println("")
+	fmt.Println("source can be:")
+	fmt.Println(" a .syx preset file")
+	fmt.Println(" a directory, all its .syx files are used")
+	fmt.Println(" a pcap file, all the PresetData found are used")
+	fmt.Println("")
+	fmt.Println("Icon and Mainframe IPs can be set to auto to detect them from the traffic")
+	fmt.Println("")
+	fmt.Println("Example:")
+	fmt.Println("", os.Args[0], "192.168.1.125 192.168.1.126 /tmp/presets /tmp/capture.pcap")
+}
+
+func main() {
+	if len(os.Args) < 4 {
+		help()
+		return
+	}
+	iconIP := os.Args[1]
+	frameIP := os.Args[2]
+
+	f, _ := os.Create("output.log")
+	logs := log.New(f, "M6kPresetLayout", log.Lshortfile)
+	defer f.Close()
+
+	var dumps []*sysex.PresetData
+	for _, source := range os.Args[3:] {
+		found, err := load(logs, iconIP, frameIP, source)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		dumps = append(dumps, found...)
+	}
+	fmt.Println(len(dumps), "dumps loaded")
+
+	for _, l := range preset.Analyze(dumps) {
+		fmt.Println()
+		fmt.Print(l.String())
+	}
+}
+
+func load(logs *log.Logger, iconIP string, frameIP string, source string) ([]*sysex.PresetData, error) {
+	info, err := os.Stat(source)
+	if err != nil {
+		return nil, err
+	}
+
+	if info.IsDir() {
+		files, err := filepath.Glob(filepath.Join(source, "*.syx"))
+		if err != nil {
+			return nil, err
+		}
+		var dumps []*sysex.PresetData
+		for _, file := range files {
+			found, err := load(logs, iconIP, frameIP, file)
+			if err != nil {
+				return nil, err
+			}
+			dumps = append(dumps, found...)
+		}
+		return dumps, nil
+	}
+
+	if strings.EqualFold(filepath.Ext(source), ".syx") {
+		data, err := os.ReadFile(source)
+		if err != nil {
+			return nil, err
+		}
+		//The layout is not validated, short dumps are also of interest
+		m, err := sysex.Unmarshal(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", source, err)
+		}
+		d, ok := m.(*sysex.PresetData)
+		if !ok {
+			return nil, fmt.Errorf("%s: %w", source, preset.ErrNotPreset)
+		}
+		return []*sysex.PresetData{d}, nil
+	}
+
+	var dumps []*sysex.PresetData
+	cap := capture.New(logs, iconIP, frameIP)
+	cap.AddTCPHandler(func(ev tcpparser.Event) {
+		if d, ok := ev.SysEx.(*sysex.PresetData); ok {
+			dumps = append(dumps, d)
+		}
+	})
+	if err := cap.ReadPcap(source); err != nil {
+		return nil, fmt.Errorf("%s: %w", source, err)
+	}
+	return dumps, nil
+}
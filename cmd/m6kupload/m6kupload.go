@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"m6kparse/client"
+	"m6kparse/decoder"
+	"m6kparse/preset"
+	"m6kparse/sysex"
+	"os"
+	"time"
+)
+
+const (
+	dialTimeout  = 5 * time.Second
+	replyTimeout = 500 * time.Millisecond
+)
+
+func help() {
+	fmt.Println("Usage:", os.Args[0], " <Mainframe IP> [-check] <syx file> [<syx file>...]")
+	fmt.Println("")
+	fmt.Println("Uploads .syx presets to a Frame, files are all validated before anything is sent")
+	fmt.Println("Each preset is read back from the Frame to confirm the upload")
+	fmt.Println("")
+	fmt.Println("-check: only validate the files")
+	fmt.Println("")
+	fmt.Println("Example:")
+	fmt.Println("", os.Args[0], "192.168.1.126 /tmp/presets/012-Hall.syx")
+}
+
+func main() {
+	if len(os.Args) < 3 {
+		help()
+		return
+	}
+	frameIP := os.Args[1]
+	files := os.Args[2:]
+	check := files[0] == "-check"
+	if check {
+		files = files[1:]
+	}
+	if len(files) == 0 {
+		help()
+		return
+	}
+
+	f, _ := os.Create("output.log")
+	logs := log.New(f, "M6kUpload", log.Lshortfile)
+	defer f.Close()
+
+	var presets []*sysex.PresetData
+	for _, file := range files {
+		m, p, err := preset.Load(file)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		fmt.Println(file+":", p.String())
+		presets = append(presets, m)
+	}
+	if check {
+		return
+	}
+
+	c, err := client.Dial(frameIP, dialTimeout, logs)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer c.Close()
+
+	//PresetData was only seen from the Frame to the Icon, the Frame accepting it from
+	//the Icon is not verified: the upload is only reported once the preset reads back
+	for _, m := range presets {
+		if err := c.Send(m); err != nil {
+			fmt.Println(err)
+			return
+		}
+
+		replies, err := c.Receive(replyTimeout)
+		for _, reply := range replies {
+			fmt.Println(" Frame replied:", describe(reply))
+		}
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+
+		if err := verify(c, m); err != nil {
+			fmt.Println("Upload failed:", m.String()+":", err)
+			return
+		}
+		fmt.Println("Sent", m.String())
+	}
+}
+
+// verify reads a preset back from the Frame and compares it with what was sent
+func verify(c *client.Client, m *sysex.PresetData) error {
+	//The last 3 request bytes are not identified, zeros are sent
+	reply, err := c.Request(&sysex.PresetRequest{Header: m.Header, Number: m.Number, Extra: make([]byte, 3)}, replyTimeout)
+	if err != nil {
+		return err
+	}
+	data := reply.(*sysex.PresetData)
+	if data.Number != m.Number || !bytes.Equal(data.Data, m.Data) {
+		return fmt.Errorf("Frame returned %s with different content", data.String())
+	}
+	return nil
+}
+
+func describe(msg decoder.Message) string {
+	if msg.Type != decoder.MessageSysEx {
+		return msg.Type.String()
+	}
+	m, err := sysex.Unmarshal(msg.Data)
+	if m == nil {
+		return err.Error()
+	}
+	return m.String()
+}
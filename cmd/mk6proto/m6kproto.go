@@ -5,11 +5,19 @@ import (
 	"fmt"
 	"log"
 	"m6kparse/capture"
+	"m6kparse/catalog"
+	"m6kparse/meter"
+	"m6kparse/preset"
+	"m6kparse/state"
+	"m6kparse/sysex"
+	"m6kparse/tcpparser"
+	"m6kparse/timecode"
 	"os"
+	"strings"
 )
 
 func help() {
-	fmt.Println("Usage:", os.Args[0], " <Icon IP> <Mainframe IP> <mode> <source>")
+	fmt.Println("Usage:", os.Args[0], " [<Icon IP> <Mainframe IP>] <mode> <source> [-icon <Icon IP>] [-frame <Mainframe IP>] [-catalog <catalog file>] [-export <directory>] [-presets <report file>] [-timecodes <analysis file>] [-timecode-layout <layout>] [-meters <csv file>] [-meter-layout <layout>]")
 	fmt.Println("")
 	fmt.Println("mode can be:")
 	fmt.Println(" -live: live capture from a network interface")
@@ -19,21 +27,50 @@ func help() {
 	fmt.Println(" In live mode, a network interface")
 	fmt.Println(" In pcap mode, a pcap file")
 	fmt.Println("")
+	fmt.Println("Icon and Mainframe IPs are detected from the traffic when not given (or set to auto)")
+	fmt.Println("")
+	fmt.Println("-icon and -frame set the Icon or the Mainframe IP")
+	fmt.Println("-catalog loads a JSON engine/parameters catalog (default is the built-in M6000 catalog)")
+	fmt.Println("-export writes the captured presets as .syx files, along with an index, into a directory")
+	fmt.Println("-presets writes the list of presets requested and received, as CSV if the file name ends with .csv, JSON otherwise")
+	fmt.Println("-timecodes writes the raw fields analysis of the timecode streams")
+	fmt.Println("-timecode-layout sets where the frame counter is in the timecode packets: offset=<n>,size=<1|2|4|8>,le")
+	fmt.Println("  Timecodes are printed as HH:MM:SS:FF only once the layout is set, as a raw counter otherwise")
+	fmt.Println("-meters writes the levels of each meter stream as a CSV time series, into <csv file>_<source>_<destination>.csv")
+	fmt.Println("-meter-layout sets where the levels are in the meter packets: offset=<n>,channels=<n>,size=<1|2>,le,fullscale=<n>")
+	fmt.Println("  By default the whole packet is read as 2 bytes raw values, levels are converted to dBFS when fullscale is set")
+	fmt.Println("")
 	fmt.Println("Example:")
+	fmt.Println("", os.Args[0], "-pcap /tmp/capture.pcap")
+	fmt.Println("", os.Args[0], "-live eth0 -frame 192.168.1.249")
 	fmt.Println("", os.Args[0], "192.168.1.125 192.168.1.125 -live eth0")
 	fmt.Println("", os.Args[0], "192.168.1.125 192.168.1.125 -pcap /tmp/capture.pcap")
 }
 
 func main() {
 	var err error
-	if len(os.Args) != 5 {
+	var iconIP, frameIP string
+	args := os.Args[1:]
+	if len(args) >= 2 && !strings.HasPrefix(args[0], "-") {
+		iconIP = args[0]
+		frameIP = args[1]
+		args = args[2:]
+	}
+	if len(args) < 2 || len(args)%2 != 0 {
 		help()
 		return
 	}
-	iconIP := os.Args[1]
-	frameIP := os.Args[2]
-	mode := os.Args[3]
-	source := os.Args[4]
+	mode := args[0]
+	source := args[1]
+	options := args[2:]
+	for i := 0; i < len(options); i += 2 {
+		switch options[i] {
+		case "-icon":
+			iconIP = options[i+1]
+		case "-frame":
+			frameIP = options[i+1]
+		}
+	}
 
 	f, _ := os.Create("output.log")
 	logs := log.New(f, "M6kParser", log.Lshortfile)
@@ -41,6 +78,68 @@ func main() {
 
 	cap := capture.New(logs, iconIP, frameIP)
 
+	var exporter *preset.Exporter
+	var presetsReport string
+	var timecodesReport string
+	var meters *meter.CSVFiles
+	for i := 0; i < len(options); i += 2 {
+		switch options[i] {
+		case "-catalog":
+			c, err := catalog.Load(options[i+1])
+			if err != nil {
+				fmt.Println(err)
+				return
+			}
+			cap.SetCatalog(c)
+		case "-export":
+			exporter, err = preset.NewExporter(options[i+1])
+			if err != nil {
+				fmt.Println(err)
+				return
+			}
+			cap.AddTCPHandler(func(ev tcpparser.Event) {
+				if data, ok := ev.SysEx.(*sysex.PresetData); ok {
+					if _, err := exporter.Add(ev.Session, ev.Timestamp, data); err != nil {
+						logs.Println("[Export]", err)
+					}
+				}
+			})
+		case "-icon", "-frame":
+		case "-presets":
+			presetsReport = options[i+1]
+		case "-timecodes":
+			timecodesReport = options[i+1]
+		case "-timecode-layout":
+			layout, err := timecode.ParseLayout(options[i+1])
+			if err != nil {
+				fmt.Println(err)
+				return
+			}
+			cap.Timecodes().SetLayout(layout)
+		case "-meters":
+			meters = meter.NewCSVFiles(options[i+1])
+			cap.Meters().AddHandler(func(frame meter.Frame) {
+				if err := meters.Write(frame); err != nil {
+					logs.Println("[Meters]", err)
+				}
+			})
+		case "-meter-layout":
+			layout, err := meter.ParseLayout(options[i+1])
+			if err != nil {
+				fmt.Println(err)
+				return
+			}
+			cap.Meters().SetLayout(layout)
+		default:
+			help()
+			return
+		}
+	}
+
+	cap.State().Subscribe(func(c state.Change) {
+		logs.Println("[State]", c.Timestamp.Format("15:04:05.000"), c.String())
+	})
+
 	if mode == "-live" {
 		err = cap.ReadLive(source)
 	} else if mode == "-pcap" {
@@ -52,5 +151,81 @@ func main() {
 
 	if err != nil {
 		fmt.Println(err)
+		return
+	}
+
+	fmt.Println(cap.Roles().Icon().String())
+	fmt.Println(cap.Roles().Frame().String())
+	report := cap.Correlator().Report()
+	logs.Print(report)
+	fmt.Print(report)
+	fmt.Println(len(cap.State().Addresses()), "parameters seen")
+	for _, frame := range cap.Discovery().Frames() {
+		fmt.Println("Mainframe:", frame.String())
+	}
+	for _, stats := range cap.Timecodes().Stats() {
+		logs.Println("Timecodes:", stats.String())
+		fmt.Println("Timecodes:", stats.String())
+	}
+	for _, stats := range cap.Meters().Stats() {
+		logs.Println("Meters:", stats.String())
+		fmt.Println("Meters:", stats.String())
+	}
+	if meters != nil {
+		if err := meters.Close(); err != nil {
+			fmt.Println(err)
+		}
+	}
+
+	if exporter != nil {
+		if err := exporter.WriteIndex(); err != nil {
+			fmt.Println(err)
+			return
+		}
+		fmt.Println(len(exporter.Entries()), "presets exported")
+	}
+
+	if presetsReport != "" {
+		if err := writePresetsReport(presetsReport, preset.Report(cap.Correlator())); err != nil {
+			fmt.Println(err)
+			return
+		}
+	}
+
+	if timecodesReport != "" {
+		if err := writeTimecodesReport(timecodesReport, cap.Timecodes()); err != nil {
+			fmt.Println(err)
+			return
+		}
+	}
+}
+
+func writeTimecodesReport(path string, d *timecode.Decoder) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	for _, stats := range d.Stats() {
+		fmt.Fprintln(f, stats.String())
+		for _, field := range timecode.Analyze(d.Packets(stats.Stream)) {
+			fmt.Fprintln(f, field.String())
+		}
+		fmt.Fprintln(f)
+	}
+	return nil
+}
+
+func writePresetsReport(path string, entries []preset.ReportEntry) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if strings.HasSuffix(strings.ToLower(path), ".csv") {
+		return preset.WriteReportCSV(f, entries)
 	}
+	return preset.WriteReportJSON(f, entries)
 }
@@ -0,0 +1,121 @@
+package codec
+
+import (
+	"errors"
+)
+
+/*
+SysEx data bytes only carry 7 bits, the M6000 uses several encodings to transport larger values:
+
+ - 14 bits values: two 7 bits bytes, most significant first
+ - Signed 14 bits values: same as above, two's complement on 14 bits
+ - 8 bits values: two bytes, the first one holding the most significant bit
+ - Nibbles: two bytes per byte, 1 nibble per byte, most significant first
+ - Nibble strings: nibble encoded characters followed by a null character (00 00)
+*/
+
+var (
+	ErrOddLength     = errors.New("odd number of bytes")
+	ErrInvalidNibble = errors.New("byte is not a nibble")
+	ErrNotTerminated = errors.New("string is not null terminated")
+)
+
+const (
+	max14Bits = 0x3FFF
+	sign14Bit = 0x2000
+)
+
+// Decode14 decodes an unsigned 14 bits value
+func Decode14(a byte, b byte) uint16 {
+	return uint16(a&0x7F)<<7 | uint16(b&0x7F)
+}
+
+// Encode14 encodes an unsigned 14 bits value, higher bits are ignored
+func Encode14(value uint16) (byte, byte) {
+	return byte(value>>7) & 0x7F, byte(value) & 0x7F
+}
+
+// DecodeSigned14 decodes a signed 14 bits value (-8192 to 8191)
+func DecodeSigned14(a byte, b byte) int16 {
+	value := Decode14(a, b)
+	if value&sign14Bit != 0 {
+		return int16(value) - (max14Bits + 1)
+	}
+	return int16(value)
+}
+
+// EncodeSigned14 encodes a signed 14 bits value (-8192 to 8191)
+func EncodeSigned14(value int16) (byte, byte) {
+	return Encode14(uint16(value) & max14Bits)
+}
+
+// Decode8 decodes an 8 bits value transported into two 7 bits bytes
+func Decode8(a byte, b byte) byte {
+	return (a&0x01)<<7 | b&0x7F
+}
+
+// Encode8 encodes an 8 bits value into two 7 bits bytes
+func Encode8(value byte) (byte, byte) {
+	return value >> 7, value & 0x7F
+}
+
+// DecodeNibble decodes a byte transported as two nibbles
+func DecodeNibble(a byte, b byte) (byte, error) {
+	if a > 0x0F || b > 0x0F {
+		return 0, ErrInvalidNibble
+	}
+	return a<<4 | b, nil
+}
+
+// EncodeNibble encodes a byte as two nibbles
+func EncodeNibble(value byte) (byte, byte) {
+	return value >> 4, value & 0x0F
+}
+
+// DecodeNibbles decodes nibble packed bytes
+func DecodeNibbles(data []byte) ([]byte, error) {
+	if len(data)%2 != 0 {
+		return nil, ErrOddLength
+	}
+	decoded := make([]byte, 0, len(data)/2)
+	for i := 0; i < len(data); i += 2 {
+		b, err := DecodeNibble(data[i], data[i+1])
+		if err != nil {
+			return nil, err
+		}
+		decoded = append(decoded, b)
+	}
+	return decoded, nil
+}
+
+// EncodeNibbles encodes bytes as nibbles
+func EncodeNibbles(data []byte) []byte {
+	encoded := make([]byte, 0, len(data)*2)
+	for _, b := range data {
+		hi, lo := EncodeNibble(b)
+		encoded = append(encoded, hi, lo)
+	}
+	return encoded
+}
+
+// DecodeNibbleString decodes a null terminated nibble packed string.
+// Returns the string and the number of bytes used, terminator included.
+func DecodeNibbleString(data []byte) (string, int, error) {
+	var str []byte
+	for i := 0; i+1 < len(data); i += 2 {
+		c, err := DecodeNibble(data[i], data[i+1])
+		if err != nil {
+			return "", 0, err
+		}
+		if c == 0 {
+			return string(str), i + 2, nil
+		}
+		str = append(str, c)
+	}
+	return "", 0, ErrNotTerminated
+}
+
+// EncodeNibbleString encodes a string as nibbles, null terminator included
+func EncodeNibbleString(str string) []byte {
+	return append(EncodeNibbles([]byte(str)), 0x00, 0x00)
+}
@@ -0,0 +1,159 @@
+package codec
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestRoundTrip14(t *testing.T) {
+	for v := 0; v <= max14Bits; v++ {
+		a, b := Encode14(uint16(v))
+		if a > 0x7F || b > 0x7F {
+			t.Fatalf("Encode14(0x%04x) = %02x %02x, not 7 bits bytes", v, a, b)
+		}
+		if got := Decode14(a, b); got != uint16(v) {
+			t.Fatalf("Decode14(Encode14(0x%04x)) = 0x%04x", v, got)
+		}
+	}
+}
+
+func TestEncode14IgnoresHigherBits(t *testing.T) {
+	a, b := Encode14(0xC123)
+	if got := Decode14(a, b); got != 0x0123 {
+		t.Fatalf("Decode14(Encode14(0xC123)) = 0x%04x, expected 0x0123", got)
+	}
+}
+
+func TestRoundTripSigned14(t *testing.T) {
+	for v := -8192; v <= 8191; v++ {
+		a, b := EncodeSigned14(int16(v))
+		if a > 0x7F || b > 0x7F {
+			t.Fatalf("EncodeSigned14(%d) = %02x %02x, not 7 bits bytes", v, a, b)
+		}
+		if got := DecodeSigned14(a, b); got != int16(v) {
+			t.Fatalf("DecodeSigned14(EncodeSigned14(%d)) = %d", v, got)
+		}
+	}
+}
+
+func TestSigned14Bounds(t *testing.T) {
+	tests := []struct {
+		a, b  byte
+		value int16
+	}{
+		{0x00, 0x00, 0},
+		{0x3F, 0x7F, 8191},
+		{0x40, 0x00, -8192},
+		{0x7F, 0x7F, -1},
+	}
+	for _, tt := range tests {
+		if got := DecodeSigned14(tt.a, tt.b); got != tt.value {
+			t.Errorf("DecodeSigned14(%02x, %02x) = %d, expected %d", tt.a, tt.b, got, tt.value)
+		}
+	}
+}
+
+func TestRoundTrip8(t *testing.T) {
+	for v := 0; v <= 0xFF; v++ {
+		a, b := Encode8(byte(v))
+		if a > 0x01 || b > 0x7F {
+			t.Fatalf("Encode8(0x%02x) = %02x %02x", v, a, b)
+		}
+		if got := Decode8(a, b); got != byte(v) {
+			t.Fatalf("Decode8(Encode8(0x%02x)) = 0x%02x", v, got)
+		}
+	}
+}
+
+func TestRoundTripNibble(t *testing.T) {
+	for v := 0; v <= 0xFF; v++ {
+		a, b := EncodeNibble(byte(v))
+		if a > 0x0F || b > 0x0F {
+			t.Fatalf("EncodeNibble(0x%02x) = %02x %02x", v, a, b)
+		}
+		got, err := DecodeNibble(a, b)
+		if err != nil {
+			t.Fatalf("DecodeNibble(EncodeNibble(0x%02x)): %v", v, err)
+		}
+		if got != byte(v) {
+			t.Fatalf("DecodeNibble(EncodeNibble(0x%02x)) = 0x%02x", v, got)
+		}
+	}
+}
+
+func TestDecodeNibbleInvalid(t *testing.T) {
+	for _, pair := range [][2]byte{{0x10, 0x00}, {0x00, 0x10}, {0x7F, 0x7F}} {
+		if _, err := DecodeNibble(pair[0], pair[1]); !errors.Is(err, ErrInvalidNibble) {
+			t.Errorf("DecodeNibble(%02x, %02x) error = %v, expected ErrInvalidNibble", pair[0], pair[1], err)
+		}
+	}
+}
+
+func TestRoundTripNibbles(t *testing.T) {
+	data := make([]byte, 256)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	encoded := EncodeNibbles(data)
+	if len(encoded) != 2*len(data) {
+		t.Fatalf("EncodeNibbles: %d bytes, expected %d", len(encoded), 2*len(data))
+	}
+	decoded, err := DecodeNibbles(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(decoded, data) {
+		t.Fatalf("DecodeNibbles(EncodeNibbles(data)) = % x", decoded)
+	}
+
+	decoded, err = DecodeNibbles(nil)
+	if err != nil || len(decoded) != 0 {
+		t.Fatalf("DecodeNibbles(nil) = % x, %v", decoded, err)
+	}
+}
+
+func TestDecodeNibblesErrors(t *testing.T) {
+	if _, err := DecodeNibbles([]byte{0x01, 0x02, 0x03}); !errors.Is(err, ErrOddLength) {
+		t.Errorf("odd length: error = %v, expected ErrOddLength", err)
+	}
+	if _, err := DecodeNibbles([]byte{0x01, 0x02, 0x03, 0x40}); !errors.Is(err, ErrInvalidNibble) {
+		t.Errorf("invalid nibble: error = %v, expected ErrInvalidNibble", err)
+	}
+}
+
+func TestRoundTripNibbleString(t *testing.T) {
+	tests := []string{"", "A", "Large Hall", "Preset #127 ~!"}
+	for _, str := range tests {
+		encoded := EncodeNibbleString(str)
+		if len(encoded) != 2*len(str)+2 {
+			t.Fatalf("EncodeNibbleString(%q): %d bytes, expected %d", str, len(encoded), 2*len(str)+2)
+		}
+		//Trailing data after the terminator must be left alone
+		got, n, err := DecodeNibbleString(append(encoded, 0x01, 0x02))
+		if err != nil {
+			t.Fatalf("DecodeNibbleString(%q): %v", str, err)
+		}
+		if got != str || n != len(encoded) {
+			t.Fatalf("DecodeNibbleString(EncodeNibbleString(%q)) = %q, %d bytes used, expected %d", str, got, n, len(encoded))
+		}
+	}
+}
+
+func TestDecodeNibbleStringErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		err  error
+	}{
+		{"empty", nil, ErrNotTerminated},
+		{"no terminator", EncodeNibbles([]byte("Hall")), ErrNotTerminated},
+		{"truncated terminator", append(EncodeNibbles([]byte("Hall")), 0x00), ErrNotTerminated},
+		{"invalid nibble", []byte{0x04, 0x41, 0x00, 0x00}, ErrInvalidNibble},
+	}
+	for _, tt := range tests {
+		if _, _, err := DecodeNibbleString(tt.data); !errors.Is(err, tt.err) {
+			t.Errorf("%s: error = %v, expected %v", tt.name, err, tt.err)
+		}
+	}
+}
@@ -14,3 +14,13 @@ func (d Direction) String() string {
 		return "Frame->Icon"
 	}
 }
+
+// StreamKey identifies a UDP stream
+type StreamKey struct {
+	Src string // ip:port
+	Dst string // ip:port
+}
+
+func (k StreamKey) String() string {
+	return k.Src + " -> " + k.Dst
+}
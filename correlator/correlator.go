@@ -0,0 +1,207 @@
+package correlator
+
+import (
+	"fmt"
+	"m6kparse/sysex"
+	"sort"
+	"time"
+)
+
+// Entry is a request or a response seen on a session
+type Entry struct {
+	Session   int
+	Timestamp time.Time
+	Message   sysex.Message
+}
+
+// Exchange is a request and its matching response
+type Exchange struct {
+	Request  Entry
+	Response Entry
+	Latency  time.Duration
+}
+
+// Stats holds the latencies of a given request type
+type Stats struct {
+	Type     byte
+	Count    int
+	Min      time.Duration
+	Max      time.Duration
+	Total    time.Duration
+	Answered int
+}
+
+type pendingKey struct {
+	session  int
+	response byte
+	address  string
+}
+
+// Correlator pairs requests with their responses, using the message type registry
+type Correlator struct {
+	pending     map[pendingKey][]Entry
+	exchanges   []Exchange
+	unanswered  []Entry
+	unsolicited []Entry
+}
+
+func New() *Correlator {
+	var c Correlator
+	c.pending = make(map[pendingKey][]Entry)
+	return &c
+}
+
+// Push adds a message seen on a session. If the message is the response
+// to a pending request, the matching exchange is returned.
+// Messages from devices other than the M6000 are ignored, their types are unknown.
+func (c *Correlator) Push(session int, ts time.Time, m sysex.Message) *Exchange {
+	if profile := m.SysExHeader().Profile; profile != nil && profile != sysex.ProfileM6000 {
+		return nil
+	}
+	info, found := sysex.Lookup(m.Type())
+	if !found || !info.HasPair {
+		return nil
+	}
+	e := Entry{Session: session, Timestamp: ts, Message: m}
+
+	if info.Request {
+		key := pendingKey{session: session, response: info.Pair, address: address(m)}
+		c.pending[key] = append(c.pending[key], e)
+		return nil
+	}
+
+	//Response, match the oldest request for the same address
+	key := pendingKey{session: session, response: m.Type(), address: address(m)}
+	requests := c.pending[key]
+	if len(requests) == 0 {
+		c.unsolicited = append(c.unsolicited, e)
+		return nil
+	}
+	if len(requests) == 1 {
+		delete(c.pending, key)
+	} else {
+		c.pending[key] = requests[1:]
+	}
+
+	x := Exchange{Request: requests[0], Response: e, Latency: ts.Sub(requests[0].Timestamp)}
+	c.exchanges = append(c.exchanges, x)
+	return &x
+}
+
+// EndSession marks all requests still pending on a session as unanswered
+func (c *Correlator) EndSession(session int) {
+	for key, requests := range c.pending {
+		if key.session == session {
+			c.unanswered = append(c.unanswered, requests...)
+			delete(c.pending, key)
+		}
+	}
+}
+
+// Exchanges returns all the matched requests and responses
+func (c *Correlator) Exchanges() []Exchange {
+	return c.exchanges
+}
+
+// Unanswered returns the requests without response, including those still pending
+func (c *Correlator) Unanswered() []Entry {
+	unanswered := append([]Entry{}, c.unanswered...)
+	for _, requests := range c.pending {
+		unanswered = append(unanswered, requests...)
+	}
+	sort.Slice(unanswered, func(i, j int) bool {
+		return unanswered[i].Timestamp.Before(unanswered[j].Timestamp)
+	})
+	return unanswered
+}
+
+// Unsolicited returns the responses received without a matching request
+func (c *Correlator) Unsolicited() []Entry {
+	return c.unsolicited
+}
+
+// Stats returns the latency statistics for each request type
+func (c *Correlator) Stats() []Stats {
+	stats := make(map[byte]*Stats)
+	get := func(t byte) *Stats {
+		s, found := stats[t]
+		if !found {
+			s = &Stats{Type: t}
+			stats[t] = s
+		}
+		return s
+	}
+
+	for _, x := range c.exchanges {
+		s := get(x.Request.Message.Type())
+		s.Count++
+		s.Answered++
+		s.Total += x.Latency
+		if s.Answered == 1 || x.Latency < s.Min {
+			s.Min = x.Latency
+		}
+		if x.Latency > s.Max {
+			s.Max = x.Latency
+		}
+	}
+	for _, e := range c.Unanswered() {
+		get(e.Message.Type()).Count++
+	}
+
+	var result []Stats
+	for _, s := range stats {
+		result = append(result, *s)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Type < result[j].Type })
+	return result
+}
+
+// Average returns the average latency of answered requests
+func (s Stats) Average() time.Duration {
+	if s.Answered == 0 {
+		return 0
+	}
+	return s.Total / time.Duration(s.Answered)
+}
+
+// Report returns a human readable summary
+func (c *Correlator) Report() string {
+	var str string
+
+	str += "Request/response latencies:\n"
+	for _, s := range c.Stats() {
+		str += fmt.Sprintf("  %-20s %5d requests %5d answered  min %v avg %v max %v\n",
+			sysex.TypeName(s.Type), s.Count, s.Answered, s.Min, s.Average(), s.Max)
+	}
+
+	unanswered := c.Unanswered()
+	str += fmt.Sprintf("Unanswered requests: %d\n", len(unanswered))
+	for _, e := range unanswered {
+		str += fmt.Sprintf("  [Session %d] %s %s\n", e.Session, e.Timestamp.Format(time.StampMicro), e.Message)
+	}
+
+	str += fmt.Sprintf("Unsolicited responses: %d\n", len(c.unsolicited))
+	for _, e := range c.unsolicited {
+		str += fmt.Sprintf("  [Session %d] %s %s\n", e.Session, e.Timestamp.Format(time.StampMicro), e.Message)
+	}
+	return str
+}
+
+// address identifies what a request or response is about
+func address(m sysex.Message) string {
+	switch msg := m.(type) {
+	case *sysex.ParamRequest:
+		return fmt.Sprintf("%d/%d/%d", msg.Engine, msg.Param, msg.Unknown)
+	case *sysex.ParamData:
+		return fmt.Sprintf("%d/%d/%d", msg.Engine, msg.Param, msg.Unknown)
+	case *sysex.PresetRequest:
+		return fmt.Sprint(msg.Number)
+	case *sysex.PresetData:
+		return fmt.Sprint(msg.Number)
+	case *sysex.RhythmRequest:
+		return fmt.Sprint(msg.Number)
+	case *sysex.RhythmData:
+		return fmt.Sprint(msg.Number)
+	}
+	return ""
+}
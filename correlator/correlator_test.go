@@ -0,0 +1,184 @@
+package correlator
+
+import (
+	"m6kparse/sysex"
+	"testing"
+	"time"
+)
+
+var start = time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+func at(ms int) time.Time {
+	return start.Add(time.Duration(ms) * time.Millisecond)
+}
+
+// message decodes a SysEx message sent to the given model
+func message(t *testing.T, model byte, msgType byte, data ...byte) sysex.Message {
+	t.Helper()
+	raw := append([]byte{0xF0, 0x00, 0x20, 0x1F, 0x00, model, msgType}, data...)
+	m, err := sysex.Unmarshal(append(raw, 0xF7))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func paramRequest(t *testing.T, engine byte, param byte) sysex.Message {
+	return message(t, sysex.ModelM6000, sysex.SYXTYPE_PARAMREQUEST, engine, param, 0x00, 0x00, 0x00, 0x02)
+}
+
+func paramData(t *testing.T, engine byte, param byte) sysex.Message {
+	return message(t, sysex.ModelM6000, sysex.SYXTYPE_PARAMDATA, engine, param, 0x00, 0x00, 0x01, 0x02, 0x03, 0x04)
+}
+
+func presetRequest(t *testing.T, number byte) sysex.Message {
+	return message(t, sysex.ModelM6000, sysex.SYXTYPE_PRESETREQUEST, number, 0x00, 0x00, 0x00, 0x00)
+}
+
+func presetData(t *testing.T, number byte) sysex.Message {
+	return message(t, sysex.ModelM6000, sysex.SYXTYPE_PRESETDATA, number, 0x00, 0x01, 0x02, 0x03)
+}
+
+func TestParamPairing(t *testing.T) {
+	c := New()
+
+	if x := c.Push(1, at(0), paramRequest(t, 6, 11)); x != nil {
+		t.Fatalf("request returned an exchange: %+v", x)
+	}
+	c.Push(1, at(1), paramRequest(t, 6, 12))
+
+	//Responses are matched by address, not by order
+	x := c.Push(1, at(5), paramData(t, 6, 12))
+	if x == nil {
+		t.Fatal("response not paired")
+	}
+	if x.Request.Message.Type() != sysex.SYXTYPE_PARAMREQUEST || x.Request.Message.(*sysex.ParamRequest).Param != 12 {
+		t.Fatalf("paired with the wrong request: %s", x.Request.Message)
+	}
+	if x.Latency != 4*time.Millisecond {
+		t.Fatalf("latency %v, expected 4ms", x.Latency)
+	}
+
+	x = c.Push(1, at(10), paramData(t, 6, 11))
+	if x == nil || x.Latency != 10*time.Millisecond {
+		t.Fatalf("unexpected exchange: %+v", x)
+	}
+	if len(c.Exchanges()) != 2 || len(c.Unanswered()) != 0 || len(c.Unsolicited()) != 0 {
+		t.Fatalf("%d exchanges, %d unanswered, %d unsolicited", len(c.Exchanges()), len(c.Unanswered()), len(c.Unsolicited()))
+	}
+}
+
+func TestPresetPairing(t *testing.T) {
+	c := New()
+
+	c.Push(1, at(0), presetRequest(t, 3))
+	c.Push(1, at(2), presetRequest(t, 3))
+
+	//Same address, the oldest request is answered first
+	x := c.Push(1, at(20), presetData(t, 3))
+	if x == nil || !x.Request.Timestamp.Equal(at(0)) || x.Latency != 20*time.Millisecond {
+		t.Fatalf("unexpected exchange: %+v", x)
+	}
+	x = c.Push(1, at(30), presetData(t, 3))
+	if x == nil || !x.Request.Timestamp.Equal(at(2)) || x.Latency != 28*time.Millisecond {
+		t.Fatalf("unexpected exchange: %+v", x)
+	}
+
+	stats := c.Stats()
+	if len(stats) != 1 || stats[0].Type != sysex.SYXTYPE_PRESETREQUEST {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+	s := stats[0]
+	if s.Count != 2 || s.Answered != 2 || s.Min != 20*time.Millisecond || s.Max != 28*time.Millisecond || s.Average() != 24*time.Millisecond {
+		t.Fatalf("unexpected stats: %+v", s)
+	}
+}
+
+func TestSessionsAreIndependent(t *testing.T) {
+	c := New()
+
+	c.Push(1, at(0), presetRequest(t, 3))
+	if x := c.Push(2, at(1), presetData(t, 3)); x != nil {
+		t.Fatalf("paired across sessions: %+v", x)
+	}
+	if len(c.Unsolicited()) != 1 || len(c.Unanswered()) != 1 {
+		t.Fatalf("%d unanswered, %d unsolicited", len(c.Unanswered()), len(c.Unsolicited()))
+	}
+}
+
+func TestUnansweredAtEndSession(t *testing.T) {
+	c := New()
+
+	c.Push(1, at(0), paramRequest(t, 6, 11))
+	c.Push(1, at(1), presetRequest(t, 3))
+	c.Push(2, at(2), presetRequest(t, 4))
+	c.EndSession(1)
+
+	//The session is over, a late response is not paired
+	if x := c.Push(1, at(3), presetData(t, 3)); x != nil {
+		t.Fatalf("paired after the end of the session: %+v", x)
+	}
+
+	unanswered := c.Unanswered()
+	if len(unanswered) != 3 {
+		t.Fatalf("%d unanswered, expected 3", len(unanswered))
+	}
+	for i, ts := range []time.Time{at(0), at(1), at(2)} {
+		if !unanswered[i].Timestamp.Equal(ts) {
+			t.Errorf("unanswered %d at %v, expected %v", i, unanswered[i].Timestamp, ts)
+		}
+	}
+
+	stats := c.Stats()
+	if len(stats) != 2 || stats[0].Count != 2 || stats[0].Answered != 0 || stats[1].Count != 1 || stats[1].Answered != 0 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+}
+
+func TestUnsolicited(t *testing.T) {
+	c := New()
+
+	if x := c.Push(1, at(0), paramData(t, 6, 11)); x != nil {
+		t.Fatalf("unexpected exchange: %+v", x)
+	}
+	c.Push(1, at(1), paramRequest(t, 6, 12))
+	if x := c.Push(1, at(2), paramData(t, 6, 11)); x != nil {
+		t.Fatalf("paired with a request for another address: %+v", x)
+	}
+
+	unsolicited := c.Unsolicited()
+	if len(unsolicited) != 2 || !unsolicited[0].Timestamp.Equal(at(0)) || !unsolicited[1].Timestamp.Equal(at(2)) {
+		t.Fatalf("unexpected unsolicited responses: %+v", unsolicited)
+	}
+	if len(c.Unanswered()) != 1 {
+		t.Fatalf("%d unanswered, expected 1", len(c.Unanswered()))
+	}
+}
+
+func TestOtherDevicesIgnored(t *testing.T) {
+	c := New()
+
+	//Same type bytes as the M6000 ParamRequest/ParamData, on an M-One and a D-Two
+	for _, model := range []byte{sysex.ProfileMOne.ModelID, sysex.ProfileDTwo.ModelID} {
+		c.Push(1, at(0), message(t, model, sysex.SYXTYPE_PARAMREQUEST, 0x01, 0x02))
+		if x := c.Push(1, at(1), message(t, model, sysex.SYXTYPE_PARAMDATA, 0x03)); x != nil {
+			t.Fatalf("foreign messages paired: %+v", x)
+		}
+	}
+	//M5000 uses its own header
+	m5000, err := sysex.Unmarshal([]byte{0xF0, 0x33, 0x00, 0x01, sysex.SYXTYPE_PRESETDATA, 0x00, 0xF7})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Push(1, at(2), m5000)
+
+	//Foreign messages must not answer M6000 requests either
+	c.Push(1, at(3), paramRequest(t, 6, 11))
+	if x := c.Push(1, at(4), message(t, sysex.ProfileMOne.ModelID, sysex.SYXTYPE_PARAMDATA, 0x06, 0x0B)); x != nil {
+		t.Fatalf("M6000 request paired with a foreign message: %+v", x)
+	}
+
+	if len(c.Exchanges()) != 0 || len(c.Unsolicited()) != 0 || len(c.Unanswered()) != 1 {
+		t.Fatalf("%d exchanges, %d unanswered, %d unsolicited", len(c.Exchanges()), len(c.Unanswered()), len(c.Unsolicited()))
+	}
+}
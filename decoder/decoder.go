@@ -0,0 +1,209 @@
+package decoder
+
+import (
+	"bytes"
+	"encoding/binary"
+	"m6kparse/common"
+)
+
+/*
+Blocks exchanged on the TCP port 1026 have the following format:
+
+ Byte 0-1 : Version (0x0002)
+ Byte 2-3 : Block size
+ Byte 4.. : MIDI data
+
+A TCP payload may carry several blocks, a block may be split over several payloads
+and a SysEx message may be split over several blocks.
+*/
+
+const (
+	blockVersion    = 0x0002
+	blockHeaderSize = 4
+)
+
+type MessageType int
+
+const (
+	MessageReset     MessageType = iota
+	MessageSysEx     MessageType = iota
+	MessageTruncated MessageType = iota
+	MessageUnknown   MessageType = iota
+)
+
+func (t MessageType) String() string {
+	switch t {
+	case MessageReset:
+		return "MIDI Reset"
+	case MessageSysEx:
+		return "MIDI SysEx"
+	case MessageTruncated:
+		return "MIDI SysEx truncated"
+	}
+	return "MIDI Unknown"
+}
+
+// Block is a complete block extracted from the stream
+type Block struct {
+	Version uint16
+	Data    []byte
+}
+
+// Message is a complete MIDI message, reassembled from one or more blocks
+type Message struct {
+	Type      MessageType
+	Direction common.Direction
+	Data      []byte
+}
+
+// Result lists what could be extracted from a chunk of data
+type Result struct {
+	Blocks   []Block
+	Messages []Message
+
+	Invalid   bool // A block with an unexpected version was found, decoder is resynchronizing
+	Pending   bool // Some data is kept until the next chunk
+	Continued bool // Data kept from a previous chunk was used
+	Skipped   int  // Number of bytes dropped while looking for a block boundary
+}
+
+type stream struct {
+	block  []byte // Incomplete block
+	sysex  []byte // Incomplete SysEx message
+	resync bool   // Block boundary is unknown
+}
+
+// Decoder extracts blocks and MIDI messages from the byte chunks of both directions
+type Decoder struct {
+	streams [2]stream
+}
+
+func New() *Decoder {
+	var d Decoder
+	return &d
+}
+
+// Reset drops any data kept for the given direction
+func (d *Decoder) Reset(dir common.Direction) {
+	d.streams[dir] = stream{}
+}
+
+// Skip tells the decoder that data was lost on the given direction.
+// Pending data is dropped (an incomplete SysEx is returned as truncated) and the
+// decoder looks for the next block boundary.
+func (d *Decoder) Skip(dir common.Direction) []Message {
+	var msgs []Message
+	s := &d.streams[dir]
+
+	if len(s.sysex) != 0 {
+		msgs = append(msgs, Message{Type: MessageTruncated, Direction: dir, Data: s.sysex})
+	}
+	*s = stream{resync: true}
+	return msgs
+}
+
+// Push feeds a chunk of data received on the given direction
+func (d *Decoder) Push(dir common.Direction, data []byte) Result {
+	var res Result
+	s := &d.streams[dir]
+
+	res.Continued = len(s.block) != 0 || len(s.sysex) != 0
+
+	buffer := append(s.block, data...)
+	offs := 0
+	for {
+		if s.resync {
+			found := findBlockStart(buffer[offs:])
+			if found < 0 {
+				//Keep the last bytes, they may be the start of a block header
+				skip := max(len(buffer)-offs-blockHeaderSize, 0)
+				res.Skipped += skip
+				offs += skip
+				break
+			}
+			res.Skipped += found
+			offs += found
+			s.resync = false
+		}
+
+		if offs+blockHeaderSize > len(buffer) {
+			break
+		}
+		version := binary.BigEndian.Uint16(buffer[offs : offs+2])
+		size := int(binary.BigEndian.Uint16(buffer[offs+2 : offs+4]))
+
+		if version != blockVersion {
+			//Lost the block boundaries, look for the next one
+			res.Invalid = true
+			res.Messages = append(res.Messages, d.Skip(dir)...)
+			offs++
+			res.Skipped++
+			continue
+		}
+
+		//Not enough room
+		if offs+blockHeaderSize+size > len(buffer) {
+			break
+		}
+
+		b := Block{Version: version, Data: bytes.Clone(buffer[offs+blockHeaderSize : offs+blockHeaderSize+size])}
+		res.Blocks = append(res.Blocks, b)
+		res.Messages = append(res.Messages, s.pushBlock(dir, b.Data)...)
+		offs += blockHeaderSize + size
+	}
+
+	s.block = bytes.Clone(buffer[offs:])
+	res.Pending = len(s.block) != 0 || len(s.sysex) != 0
+	return res
+}
+
+// findBlockStart looks for something that looks like the header of a block
+// starting a new MIDI message. Returns -1 if none was found.
+func findBlockStart(data []byte) int {
+	for i := 0; i+blockHeaderSize < len(data); i++ {
+		version := binary.BigEndian.Uint16(data[i : i+2])
+		size := binary.BigEndian.Uint16(data[i+2 : i+4])
+		first := data[i+blockHeaderSize]
+		if version == blockVersion && size != 0 && (first == 0xF0 || first == 0xFF) {
+			return i
+		}
+	}
+	return -1
+}
+
+// pushBlock extracts the MIDI message carried by a block
+func (s *stream) pushBlock(dir common.Direction, data []byte) []Message {
+	var msgs []Message
+
+	if len(data) == 0 {
+		return msgs
+	}
+
+	//Previous SysEx was truncated, continue it unless a new message starts here
+	if len(s.sysex) != 0 {
+		if isStatusByte(data[0]) && data[0] != 0xF7 {
+			msgs = append(msgs, Message{Type: MessageTruncated, Direction: dir, Data: s.sysex})
+		} else {
+			data = append(s.sysex, data...)
+		}
+		s.sysex = nil
+	}
+
+	switch data[0] {
+	case 0xF0:
+		if data[len(data)-1] != 0xF7 {
+			s.sysex = bytes.Clone(data)
+			return msgs
+		}
+		msgs = append(msgs, Message{Type: MessageSysEx, Direction: dir, Data: data})
+	case 0xFF:
+		msgs = append(msgs, Message{Type: MessageReset, Direction: dir, Data: data})
+	default:
+		msgs = append(msgs, Message{Type: MessageUnknown, Direction: dir, Data: data})
+	}
+	return msgs
+}
+
+func isStatusByte(b byte) bool {
+	return b&0x80 != 0
+}
@@ -0,0 +1,199 @@
+package decoder
+
+import (
+	"bytes"
+	"m6kparse/common"
+	"testing"
+)
+
+// block frames MIDI data into a version 2 block
+func block(data ...byte) []byte {
+	return append([]byte{0x00, 0x02, byte(len(data) >> 8), byte(len(data))}, data...)
+}
+
+func concat(chunks ...[]byte) []byte {
+	var data []byte
+	for _, c := range chunks {
+		data = append(data, c...)
+	}
+	return data
+}
+
+func checkMessages(t *testing.T, got []Message, expected ...Message) {
+	t.Helper()
+	if len(got) != len(expected) {
+		t.Fatalf("%d messages, expected %d: %v", len(got), len(expected), got)
+	}
+	for i := range got {
+		if got[i].Type != expected[i].Type || got[i].Direction != expected[i].Direction || !bytes.Equal(got[i].Data, expected[i].Data) {
+			t.Errorf("message %d: %v %v % x, expected %v %v % x", i,
+				got[i].Type, got[i].Direction, got[i].Data,
+				expected[i].Type, expected[i].Direction, expected[i].Data)
+		}
+	}
+}
+
+var paramRequest = []byte{0xF0, 0x00, 0x20, 0x1F, 0x00, 0x46, 0x47, 0x01, 0x02, 0xF7}
+
+func TestSingleBlock(t *testing.T) {
+	d := New()
+	res := d.Push(common.IconToFrame, block(paramRequest...))
+
+	if res.Invalid || res.Pending || res.Continued || res.Skipped != 0 {
+		t.Fatalf("unexpected status: %+v", res)
+	}
+	if len(res.Blocks) != 1 || res.Blocks[0].Version != blockVersion || !bytes.Equal(res.Blocks[0].Data, paramRequest) {
+		t.Fatalf("unexpected blocks: %+v", res.Blocks)
+	}
+	checkMessages(t, res.Messages, Message{Type: MessageSysEx, Direction: common.IconToFrame, Data: paramRequest})
+}
+
+func TestBlockSplitAcrossChunks(t *testing.T) {
+	d := New()
+	data := block(paramRequest...)
+
+	//Split inside the header, then inside the MIDI data
+	res := d.Push(common.FrameToIcon, data[:3])
+	if !res.Pending || len(res.Blocks) != 0 || len(res.Messages) != 0 {
+		t.Fatalf("first chunk: %+v", res)
+	}
+	res = d.Push(common.FrameToIcon, data[3:8])
+	if !res.Pending || !res.Continued || len(res.Blocks) != 0 || len(res.Messages) != 0 {
+		t.Fatalf("second chunk: %+v", res)
+	}
+	res = d.Push(common.FrameToIcon, data[8:])
+	if res.Pending || !res.Continued || res.Invalid {
+		t.Fatalf("last chunk: %+v", res)
+	}
+	//Data kept between chunks must only be used once
+	if len(res.Blocks) != 1 || !bytes.Equal(res.Blocks[0].Data, paramRequest) {
+		t.Fatalf("unexpected blocks: %+v", res.Blocks)
+	}
+	checkMessages(t, res.Messages, Message{Type: MessageSysEx, Direction: common.FrameToIcon, Data: paramRequest})
+}
+
+func TestSysExSplitAcrossBlocks(t *testing.T) {
+	d := New()
+
+	res := d.Push(common.FrameToIcon, block(paramRequest[:6]...))
+	if !res.Pending || len(res.Blocks) != 1 || len(res.Messages) != 0 {
+		t.Fatalf("first block: %+v", res)
+	}
+	res = d.Push(common.FrameToIcon, concat(block(paramRequest[6:8]...), block(paramRequest[8:]...)))
+	if res.Pending || !res.Continued || len(res.Blocks) != 2 {
+		t.Fatalf("last blocks: %+v", res)
+	}
+	checkMessages(t, res.Messages, Message{Type: MessageSysEx, Direction: common.FrameToIcon, Data: paramRequest})
+}
+
+func TestSeveralBlocksInOneChunk(t *testing.T) {
+	d := New()
+	reset := []byte{0xFF, 0x00, 0x00}
+	other := []byte{0xF0, 0x00, 0x20, 0x1F, 0x00, 0x46, 0x45, 0x03, 0x00, 0xF7}
+
+	res := d.Push(common.IconToFrame, concat(block(reset...), block(paramRequest...), block(other...)))
+	if res.Pending || res.Invalid || len(res.Blocks) != 3 {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+	checkMessages(t, res.Messages,
+		Message{Type: MessageReset, Direction: common.IconToFrame, Data: reset},
+		Message{Type: MessageSysEx, Direction: common.IconToFrame, Data: paramRequest},
+		Message{Type: MessageSysEx, Direction: common.IconToFrame, Data: other})
+}
+
+func TestResetCutsPendingSysEx(t *testing.T) {
+	d := New()
+	reset := []byte{0xFF, 0x00, 0x00}
+
+	res := d.Push(common.IconToFrame, concat(block(paramRequest[:5]...), block(reset...)))
+	if res.Pending {
+		t.Fatalf("truncated SysEx still pending: %+v", res)
+	}
+	checkMessages(t, res.Messages,
+		Message{Type: MessageTruncated, Direction: common.IconToFrame, Data: paramRequest[:5]},
+		Message{Type: MessageReset, Direction: common.IconToFrame, Data: reset})
+}
+
+func TestDirectionsAreIndependent(t *testing.T) {
+	d := New()
+	data := block(paramRequest...)
+
+	d.Push(common.IconToFrame, data[:6])
+	res := d.Push(common.FrameToIcon, data)
+	checkMessages(t, res.Messages, Message{Type: MessageSysEx, Direction: common.FrameToIcon, Data: paramRequest})
+
+	res = d.Push(common.IconToFrame, data[6:])
+	checkMessages(t, res.Messages, Message{Type: MessageSysEx, Direction: common.IconToFrame, Data: paramRequest})
+}
+
+func TestInvalidVersionResync(t *testing.T) {
+	d := New()
+	garbage := []byte{0x00, 0x03, 0x00, 0x01, 0xAA}
+
+	//A SysEx is pending when the stream gets corrupted
+	d.Push(common.FrameToIcon, block(paramRequest[:4]...))
+	res := d.Push(common.FrameToIcon, concat(garbage, block(paramRequest...)))
+	if !res.Invalid {
+		t.Fatalf("invalid version not reported: %+v", res)
+	}
+	if res.Skipped != len(garbage) {
+		t.Fatalf("%d bytes skipped, expected %d", res.Skipped, len(garbage))
+	}
+	if res.Pending {
+		t.Fatalf("data still pending after resync: %+v", res)
+	}
+	checkMessages(t, res.Messages,
+		Message{Type: MessageTruncated, Direction: common.FrameToIcon, Data: paramRequest[:4]},
+		Message{Type: MessageSysEx, Direction: common.FrameToIcon, Data: paramRequest})
+
+	//Back in sync
+	res = d.Push(common.FrameToIcon, block(paramRequest...))
+	if res.Invalid || res.Skipped != 0 {
+		t.Fatalf("not resynchronized: %+v", res)
+	}
+	checkMessages(t, res.Messages, Message{Type: MessageSysEx, Direction: common.FrameToIcon, Data: paramRequest})
+}
+
+func TestInvalidVersionWithoutBoundary(t *testing.T) {
+	d := New()
+	garbage := []byte{0x00, 0x03, 0x00, 0x01, 0xAA, 0xBB, 0xCC, 0xDD, 0xEE}
+
+	res := d.Push(common.FrameToIcon, garbage)
+	if !res.Invalid || len(res.Messages) != 0 {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+	//The last bytes are kept, they may start a block header
+	if res.Skipped != len(garbage)-blockHeaderSize {
+		t.Fatalf("%d bytes skipped, expected %d", res.Skipped, len(garbage)-blockHeaderSize)
+	}
+
+	res = d.Push(common.FrameToIcon, block(paramRequest...))
+	if res.Skipped != blockHeaderSize {
+		t.Fatalf("%d bytes skipped, expected %d", res.Skipped, blockHeaderSize)
+	}
+	checkMessages(t, res.Messages, Message{Type: MessageSysEx, Direction: common.FrameToIcon, Data: paramRequest})
+}
+
+func TestSkipAfterGap(t *testing.T) {
+	d := New()
+	lost := block(0xF0, 0x00, 0x20, 0x1F, 0x00, 0x46, 0x20, 0x01, 0x02, 0x03, 0xF7)
+
+	d.Push(common.FrameToIcon, block(paramRequest[:6]...))
+	msgs := d.Skip(common.FrameToIcon)
+	checkMessages(t, msgs, Message{Type: MessageTruncated, Direction: common.FrameToIcon, Data: paramRequest[:6]})
+
+	//Capture resumes in the middle of a block
+	res := d.Push(common.FrameToIcon, concat(lost[7:], block(paramRequest...)))
+	if res.Skipped != len(lost)-7 {
+		t.Fatalf("%d bytes skipped, expected %d", res.Skipped, len(lost)-7)
+	}
+	if res.Continued {
+		t.Fatalf("data kept across a gap: %+v", res)
+	}
+	checkMessages(t, res.Messages, Message{Type: MessageSysEx, Direction: common.FrameToIcon, Data: paramRequest})
+
+	//Nothing pending, Skip has nothing to report
+	if msgs := d.Skip(common.FrameToIcon); len(msgs) != 0 {
+		t.Fatalf("unexpected messages: %v", msgs)
+	}
+}
@@ -0,0 +1,24 @@
+package decoder
+
+import "encoding/binary"
+
+// MaxBlockData is the largest MIDI data size a block can carry
+const MaxBlockData = 0xFFFF
+
+// EncodeBlocks frames MIDI data into blocks of at most maxSize bytes of data,
+// a SysEx message larger than maxSize is split over several blocks.
+func EncodeBlocks(data []byte, maxSize int) []byte {
+	if maxSize <= 0 || maxSize > MaxBlockData {
+		maxSize = MaxBlockData
+	}
+
+	blocks := make([]byte, 0, len(data)+blockHeaderSize*(len(data)/maxSize+1))
+	for len(data) != 0 {
+		size := min(len(data), maxSize)
+		blocks = binary.BigEndian.AppendUint16(blocks, blockVersion)
+		blocks = binary.BigEndian.AppendUint16(blocks, uint16(size))
+		blocks = append(blocks, data[:size]...)
+		data = data[size:]
+	}
+	return blocks
+}
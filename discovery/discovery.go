@@ -0,0 +1,277 @@
+package discovery
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+/*
+Discovery messages are exchanged over UDP, they all start with the magic 0x12345678.
+
+Icon probe, broadcast by the Icon:
+
+ Byte 0-3 : Magic
+ Byte 4-13 : Icon name ("TCIcon"), null terminated
+ Byte 14.. : Unknown data (62 bytes)
+
+Meter port message, sent by the Icon after discovery, same layout with "METER PORT" as name.
+
+Frame response, 4 of them are sent by a Frame to a probe (TEXT, DISK, MIDI, METER entries):
+
+ Byte 0-3 : Magic
+ Byte 4-7 : Frame serial number, big endian
+ Byte 8 : Number of responses (not sure)
+ Byte 9-18 : Unknown data
+ Byte 19 : Response index, from 0 (not sure)
+ Byte 20-51 : Entry name ("112255_M6000X_MIDI"), null terminated
+ Byte 52-83 : Unknown data
+ Byte 84-102 : Device name ("TC Electronic S6000"), null terminated
+ Byte 103.. : Unknown data
+*/
+
+const (
+	Magic     = 0x12345678
+	MagicSize = 4
+
+	ProbeName     = "TCIcon"
+	MeterPortName = "METER PORT"
+
+	nameOffset = 4
+	nameSize   = 10
+	probeSize  = nameOffset + nameSize + 62
+)
+
+// Frame response fields
+const (
+	SerialOffset      = 4
+	CountOffset       = 8
+	unknownAOffset    = 9
+	IndexOffset       = 0x13
+	EntryOffset       = 0x14
+	EntrySize         = 0x20
+	unknownBOffset    = EntryOffset + EntrySize
+	DeviceOffset      = 0x54
+	DeviceSize        = 0x13
+	FrameResponseSize = DeviceOffset + DeviceSize
+)
+
+var (
+	ErrTooShort       = errors.New("discovery message too short")
+	ErrBadMagic       = errors.New("invalid discovery magic")
+	ErrUnexpectedName = errors.New("unexpected discovery message name")
+	ErrFieldTooLong   = errors.New("value too long for its field")
+)
+
+// Message is a decoded discovery message
+type Message interface {
+	// String returns a one line human readable description
+	String() string
+	// Encode returns the UDP payload of the message
+	Encode() ([]byte, error)
+}
+
+// Probe is broadcast by the Icon to find the Frames
+type Probe struct {
+	Name    string // ProbeName
+	Unknown []byte // 62 zeros if not set
+}
+
+// MeterPort is sent by the Icon after discovery
+type MeterPort struct {
+	Unknown []byte // 62 zeros if not set
+}
+
+// FrameResponse is sent by a Frame, one per entry, in response to a probe
+type FrameResponse struct {
+	Serial     uint32
+	Count      byte // Number of responses
+	Index      byte // Response index, from 0
+	EntryName  string
+	DeviceName string
+	UnknownA   []byte // Bytes 9 to 18, zeros if not set
+	UnknownB   []byte // Bytes 52 to 83, zeros if not set
+	Trailer    []byte // Bytes after the device name
+}
+
+// Decode identifies and decodes a discovery message
+func Decode(data []byte) (Message, error) {
+	if err := checkMagic(data); err != nil {
+		return nil, err
+	}
+	if len(data) >= nameOffset+nameSize {
+		switch readString(data[nameOffset : nameOffset+nameSize]) {
+		case ProbeName:
+			return DecodeProbe(data)
+		case MeterPortName:
+			return DecodeMeterPort(data)
+		}
+	}
+	return DecodeFrameResponse(data)
+}
+
+// IsDiscovery returns true if data starts with the discovery magic
+func IsDiscovery(data []byte) bool {
+	return checkMagic(data) == nil
+}
+
+func DecodeProbe(data []byte) (*Probe, error) {
+	var p Probe
+
+	name, unknown, err := decodeNamed(data)
+	if err != nil {
+		return nil, err
+	}
+	if name != ProbeName {
+		return nil, fmt.Errorf("%w: %q", ErrUnexpectedName, name)
+	}
+	p.Name = name
+	p.Unknown = unknown
+	return &p, nil
+}
+
+func (p *Probe) String() string {
+	return fmt.Sprintf("Icon probe '%s'", p.Name)
+}
+
+func (p *Probe) Encode() ([]byte, error) {
+	name := p.Name
+	if name == "" {
+		name = ProbeName
+	}
+	return encodeNamed(name, p.Unknown)
+}
+
+func DecodeMeterPort(data []byte) (*MeterPort, error) {
+	var m MeterPort
+
+	name, unknown, err := decodeNamed(data)
+	if err != nil {
+		return nil, err
+	}
+	if name != MeterPortName {
+		return nil, fmt.Errorf("%w: %q", ErrUnexpectedName, name)
+	}
+	m.Unknown = unknown
+	return &m, nil
+}
+
+func (m *MeterPort) String() string {
+	return "Icon meter port"
+}
+
+func (m *MeterPort) Encode() ([]byte, error) {
+	return encodeNamed(MeterPortName, m.Unknown)
+}
+
+func DecodeFrameResponse(data []byte) (*FrameResponse, error) {
+	var r FrameResponse
+
+	if err := checkMagic(data); err != nil {
+		return nil, err
+	}
+	if len(data) < FrameResponseSize {
+		return nil, fmt.Errorf("%w: %d bytes, %d expected", ErrTooShort, len(data), FrameResponseSize)
+	}
+	r.Serial = binary.BigEndian.Uint32(data[SerialOffset:])
+	r.Count = data[CountOffset]
+	r.Index = data[IndexOffset]
+	r.EntryName = readString(data[EntryOffset : EntryOffset+EntrySize])
+	r.DeviceName = readString(data[DeviceOffset : DeviceOffset+DeviceSize])
+	r.UnknownA = bytes.Clone(data[unknownAOffset:IndexOffset])
+	r.UnknownB = bytes.Clone(data[unknownBOffset:DeviceOffset])
+	r.Trailer = bytes.Clone(data[FrameResponseSize:])
+	return &r, nil
+}
+
+// Service returns the entry name suffix (TEXT, DISK, MIDI or METER)
+func (r *FrameResponse) Service() string {
+	return r.EntryName[strings.LastIndex(r.EntryName, "_")+1:]
+}
+
+func (r *FrameResponse) String() string {
+	return fmt.Sprintf("Frame response %d/%d serial %d entry '%s' device '%s'", r.Index+1, r.Count, r.Serial, r.EntryName, r.DeviceName)
+}
+
+func (r *FrameResponse) Encode() ([]byte, error) {
+	data := make([]byte, FrameResponseSize, FrameResponseSize+len(r.Trailer))
+	binary.BigEndian.PutUint32(data, Magic)
+	binary.BigEndian.PutUint32(data[SerialOffset:], r.Serial)
+	data[CountOffset] = r.Count
+	data[IndexOffset] = r.Index
+	if err := writeField(data[unknownAOffset:IndexOffset], r.UnknownA); err != nil {
+		return nil, err
+	}
+	if err := writeString(data[EntryOffset:EntryOffset+EntrySize], r.EntryName); err != nil {
+		return nil, err
+	}
+	if err := writeField(data[unknownBOffset:DeviceOffset], r.UnknownB); err != nil {
+		return nil, err
+	}
+	if err := writeString(data[DeviceOffset:DeviceOffset+DeviceSize], r.DeviceName); err != nil {
+		return nil, err
+	}
+	return append(data, r.Trailer...), nil
+}
+
+func checkMagic(data []byte) error {
+	if len(data) < MagicSize {
+		return ErrTooShort
+	}
+	if binary.BigEndian.Uint32(data) != Magic {
+		return ErrBadMagic
+	}
+	return nil
+}
+
+// decodeNamed decodes the Icon messages, made of a name and unknown data
+func decodeNamed(data []byte) (string, []byte, error) {
+	if err := checkMagic(data); err != nil {
+		return "", nil, err
+	}
+	if len(data) < nameOffset+nameSize {
+		return "", nil, fmt.Errorf("%w: %d bytes, at least %d expected", ErrTooShort, len(data), nameOffset+nameSize)
+	}
+	return readString(data[nameOffset : nameOffset+nameSize]), bytes.Clone(data[nameOffset+nameSize:]), nil
+}
+
+func encodeNamed(name string, unknown []byte) ([]byte, error) {
+	size := probeSize
+	if unknown != nil {
+		size = nameOffset + nameSize + len(unknown)
+	}
+	data := make([]byte, size)
+	binary.BigEndian.PutUint32(data, Magic)
+	if err := writeString(data[nameOffset:nameOffset+nameSize], name); err != nil {
+		return nil, err
+	}
+	copy(data[nameOffset+nameSize:], unknown)
+	return data, nil
+}
+
+// readString returns a string up to its null terminator, if any
+func readString(field []byte) string {
+	if i := strings.IndexByte(string(field), 0); i >= 0 {
+		field = field[:i]
+	}
+	return string(field)
+}
+
+// writeString copies a string into a zero filled field, the terminator is omitted if the string fills the field
+func writeString(field []byte, str string) error {
+	if len(str) > len(field) {
+		return fmt.Errorf("%w: %q, %d bytes max", ErrFieldTooLong, str, len(field))
+	}
+	copy(field, str)
+	return nil
+}
+
+func writeField(field []byte, data []byte) error {
+	if len(data) > len(field) {
+		return fmt.Errorf("%w: %d bytes, %d bytes max", ErrFieldTooLong, len(data), len(field))
+	}
+	copy(field, data)
+	return nil
+}
@@ -0,0 +1,117 @@
+package discovery
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+)
+
+// Port is the UDP port of the discovery traffic
+const Port = 17
+
+const (
+	DefaultDeviceName = "TC Electronic S6000"
+	DefaultModel      = "M6000X"
+	maxDatagram       = 1500
+)
+
+// DefaultServices are the entries advertised by a Frame, in response order
+var DefaultServices = []string{"TEXT", "DISK", "MIDI", "METER"}
+
+// NewResponseSet builds the responses of a Frame to a probe, one per entry
+func NewResponseSet(serial uint32, deviceName string, entryNames []string) []*FrameResponse {
+	var responses []*FrameResponse
+	for i, entry := range entryNames {
+		responses = append(responses, &FrameResponse{
+			Serial:     serial,
+			Count:      byte(len(entryNames)),
+			Index:      byte(i),
+			EntryName:  entry,
+			DeviceName: deviceName,
+		})
+	}
+	return responses
+}
+
+// EntryNames returns the default entry names of a Frame ("112255_M6000X_TEXT", ...)
+func EntryNames(serial uint32) []string {
+	var names []string
+	for _, service := range DefaultServices {
+		names = append(names, fmt.Sprintf("%d_%s_%s", serial, DefaultModel, service))
+	}
+	return names
+}
+
+// Responder answers the Icon probes as a Frame would
+type Responder struct {
+	logs      *log.Logger
+	conn      *net.UDPConn
+	responses [][]byte
+}
+
+// Listen opens the UDP socket a Responder answers on, address is "ip:port"
+func Listen(address string, responses []*FrameResponse, logs *log.Logger) (*Responder, error) {
+	var r Responder
+
+	for _, resp := range responses {
+		data, err := resp.Encode()
+		if err != nil {
+			return nil, err
+		}
+		r.responses = append(r.responses, data)
+	}
+
+	addr, err := net.ResolveUDPAddr("udp4", address)
+	if err != nil {
+		return nil, err
+	}
+	r.conn, err = net.ListenUDP("udp4", addr)
+	if err != nil {
+		return nil, err
+	}
+	r.logs = logs
+	return &r, nil
+}
+
+// Addr returns the address the Responder listens on
+func (r *Responder) Addr() net.Addr {
+	return r.conn.LocalAddr()
+}
+
+// Serve answers the probes until the Responder is closed.
+// Responses are sent back to the address the probe came from. Whether a real Frame
+// does the same, rather than answering to port 17, has not been checked on a capture.
+func (r *Responder) Serve() error {
+	buf := make([]byte, maxDatagram)
+	for {
+		n, src, err := r.conn.ReadFromUDP(buf)
+		if errors.Is(err, net.ErrClosed) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		m, err := Decode(buf[:n])
+		if err != nil {
+			r.logs.Printf("[Responder] Ignoring %d bytes from %s: %s", n, src, err)
+			continue
+		}
+		if _, ok := m.(*Probe); !ok {
+			r.logs.Printf("[Responder] Ignoring %s from %s", m.String(), src)
+			continue
+		}
+
+		r.logs.Printf("[Responder] %s from %s, sending %d responses", m.String(), src, len(r.responses))
+		for _, resp := range r.responses {
+			if _, err := r.conn.WriteToUDP(resp, src); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (r *Responder) Close() error {
+	return r.conn.Close()
+}
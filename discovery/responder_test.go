@@ -0,0 +1,51 @@
+package discovery
+
+import (
+	"io"
+	"log"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestResponderScan(t *testing.T) {
+	const serial = 112255
+	const deviceName = "Test S6000"
+	logs := log.New(io.Discard, "", 0)
+
+	r, err := Listen("127.0.0.1:0", NewResponseSet(serial, deviceName, EntryNames(serial)), logs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	served := make(chan error, 1)
+	go func() { served <- r.Serve() }()
+
+	frames, err := Scan(r.Addr().String(), 500*time.Millisecond, logs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r.Close()
+	if err := <-served; err != nil {
+		t.Fatalf("Serve: %v", err)
+	}
+
+	if len(frames) != 1 {
+		t.Fatalf("%d Mainframes found, expected 1: %v", len(frames), frames)
+	}
+	f := frames[0]
+	if !f.Complete() || f.Count != 4 || f.Received != 4 {
+		t.Fatalf("incomplete Mainframe: %s", f)
+	}
+	if f.Serial != serial || f.DeviceName != deviceName || f.IP != "127.0.0.1" {
+		t.Fatalf("unexpected Mainframe: %s", f)
+	}
+	services := slices.Clone(DefaultServices)
+	slices.Sort(services)
+	if !slices.Equal(f.Services, services) {
+		t.Fatalf("services %v, expected %v", f.Services, services)
+	}
+	if !slices.Equal(f.EntryNames, []string{"112255_M6000X_DISK", "112255_M6000X_METER", "112255_M6000X_MIDI", "112255_M6000X_TEXT"}) {
+		t.Fatalf("unexpected entries %v", f.EntryNames)
+	}
+}
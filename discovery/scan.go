@@ -0,0 +1,60 @@
+package discovery
+
+import (
+	"errors"
+	"log"
+	"net"
+	"os"
+	"time"
+)
+
+// Scan broadcasts an Icon probe to target ("ip:port") and returns the Mainframes
+// that answered within timeout.
+// The probe is sent from an ephemeral port and the responses are read on it, as the
+// Responder answers to the source port of the probe. A real Frame answering there is
+// not verified: if it answers to port 17, nothing is found.
+func Scan(target string, timeout time.Duration, logs *log.Logger) ([]Mainframe, error) {
+	probe, err := (&Probe{}).Encode()
+	if err != nil {
+		return nil, err
+	}
+	addr, err := net.ResolveUDPAddr("udp4", target)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.ListenUDP("udp4", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	tracker := NewTracker()
+	tracker.AddHandler(func(ev Event) {
+		logs.Println("[Scan] " + ev.String())
+	})
+
+	if _, err := conn.WriteToUDP(probe, addr); err != nil {
+		return nil, err
+	}
+	conn.SetReadDeadline(time.Now().Add(timeout))
+
+	buf := make([]byte, maxDatagram)
+	for {
+		n, src, err := conn.ReadFromUDP(buf)
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		r, err := DecodeFrameResponse(buf[:n])
+		if err != nil {
+			logs.Printf("[Scan] Ignoring %d bytes from %s: %s", n, src, err)
+			continue
+		}
+		tracker.Push(src.IP.String(), time.Now(), r)
+	}
+	tracker.Flush()
+	return tracker.Frames(), nil
+}
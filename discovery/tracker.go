@@ -0,0 +1,191 @@
+package discovery
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Responses to a probe arrive within this delay, a response set still incomplete after it is reported
+const responseTimeout = 2 * time.Second
+
+type EventType int
+
+const (
+	EventFrameAppeared EventType = iota // First response of an unknown Frame
+	EventComplete      EventType = iota // All the responses of a set were received
+	EventIncomplete    EventType = iota // Some responses of a set are missing
+)
+
+func (t EventType) String() string {
+	switch t {
+	case EventFrameAppeared:
+		return "Frame appeared"
+	case EventComplete:
+		return "Responses complete"
+	case EventIncomplete:
+		return "Responses incomplete"
+	}
+	return "Unknown"
+}
+
+// Event is emitted by the Tracker, Frame is a copy of the record when the event occurred
+type Event struct {
+	Type      EventType
+	Frame     Mainframe
+	Timestamp time.Time
+}
+
+func (ev Event) String() string {
+	return ev.Type.String() + ": " + ev.Frame.String()
+}
+
+type Handler func(ev Event)
+
+// Mainframe is assembled from the responses of a Frame
+type Mainframe struct {
+	Serial     uint32
+	IP         string
+	DeviceName string
+	EntryNames []string // All entries seen, sorted
+	Services   []string // Entry name suffixes (TEXT, DISK, MIDI, METER), sorted
+	Count      byte     // Number of responses announced in the last set
+	Received   int      // Number of responses received in the last set
+	FirstSeen  time.Time
+	LastSeen   time.Time
+}
+
+// Complete returns true if all the responses of the last set were received.
+// A set announcing no response is never complete.
+func (m Mainframe) Complete() bool {
+	return m.Count > 0 && m.Received >= int(m.Count)
+}
+
+func (m Mainframe) String() string {
+	return fmt.Sprintf("%s serial %d at %s, services %s, %d/%d responses", m.DeviceName, m.Serial, m.IP, strings.Join(m.Services, "/"), m.Received, m.Count)
+}
+
+type frameState struct {
+	frame    Mainframe
+	setStart time.Time
+	received map[byte]bool // Response indexes of the current set
+	closed   bool          // Current set was reported
+}
+
+// Tracker aggregates the Frame responses into Mainframe records
+type Tracker struct {
+	frames   map[uint32]*frameState
+	handlers []Handler
+}
+
+func NewTracker() *Tracker {
+	var t Tracker
+	t.frames = make(map[uint32]*frameState)
+	return &t
+}
+
+// AddHandler registers a function called for each event
+func (t *Tracker) AddHandler(h Handler) {
+	t.handlers = append(t.handlers, h)
+}
+
+// Push adds a response received from a Frame
+func (t *Tracker) Push(ip string, ts time.Time, r *FrameResponse) {
+	t.Check(ts)
+
+	s, found := t.frames[r.Serial]
+	if !found {
+		s = &frameState{frame: Mainframe{Serial: r.Serial, FirstSeen: ts}}
+		t.frames[r.Serial] = s
+	}
+
+	//A response index seen again starts a new set
+	if !found || s.received[r.Index] || s.closed {
+		t.closeSet(s, ts)
+		s.setStart = ts
+		s.received = make(map[byte]bool)
+		s.closed = false
+	}
+
+	s.received[r.Index] = true
+	s.frame.IP = ip
+	s.frame.DeviceName = r.DeviceName
+	s.frame.Count = r.Count
+	s.frame.Received = len(s.received)
+	s.frame.LastSeen = ts
+	s.frame.EntryNames = addSorted(s.frame.EntryNames, r.EntryName)
+	s.frame.Services = addSorted(s.frame.Services, r.Service())
+
+	if !found {
+		t.emit(EventFrameAppeared, s, ts)
+	}
+	if s.frame.Complete() {
+		s.closed = true
+		t.emit(EventComplete, s, ts)
+	}
+}
+
+// Check reports the response sets still incomplete after the response timeout
+func (t *Tracker) Check(ts time.Time) {
+	for _, s := range t.sorted() {
+		if !s.closed && ts.Sub(s.setStart) > responseTimeout {
+			t.closeSet(s, ts)
+		}
+	}
+}
+
+// Flush reports all the response sets still incomplete
+func (t *Tracker) Flush() {
+	for _, s := range t.sorted() {
+		t.closeSet(s, s.frame.LastSeen)
+	}
+}
+
+// Frames returns the Mainframes seen, sorted by serial number
+func (t *Tracker) Frames() []Mainframe {
+	var frames []Mainframe
+	for _, s := range t.sorted() {
+		frames = append(frames, s.copyFrame())
+	}
+	return frames
+}
+
+func (t *Tracker) closeSet(s *frameState, ts time.Time) {
+	if s.received == nil || s.closed {
+		return
+	}
+	s.closed = true
+	t.emit(EventIncomplete, s, ts)
+}
+
+func (t *Tracker) emit(evType EventType, s *frameState, ts time.Time) {
+	ev := Event{Type: evType, Frame: s.copyFrame(), Timestamp: ts}
+	for _, h := range t.handlers {
+		h(ev)
+	}
+}
+
+func (s *frameState) copyFrame() Mainframe {
+	frame := s.frame
+	frame.EntryNames = append([]string{}, s.frame.EntryNames...)
+	frame.Services = append([]string{}, s.frame.Services...)
+	return frame
+}
+
+func (t *Tracker) sorted() []*frameState {
+	states := make([]*frameState, 0, len(t.frames))
+	for _, s := range t.frames {
+		states = append(states, s)
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i].frame.Serial < states[j].frame.Serial })
+	return states
+}
+
+func addSorted(list []string, str string) []string {
+	i := sort.SearchStrings(list, str)
+	if i < len(list) && list[i] == str {
+		return list
+	}
+	return append(list[:i], append([]string{str}, list[i:]...)...)
+}
@@ -0,0 +1,157 @@
+package discovery
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+)
+
+const trackerSerial = 112255
+
+var trackerStart = time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+// responseSet builds the 4 responses of a Frame
+func responseSet(serial uint32) []*FrameResponse {
+	var responses []*FrameResponse
+	for i, service := range []string{"TEXT", "DISK", "MIDI", "METER"} {
+		responses = append(responses, &FrameResponse{
+			Serial:     serial,
+			Count:      4,
+			Index:      byte(i),
+			EntryName:  fmt.Sprintf("%d_M6000X_%s", serial, service),
+			DeviceName: "TC Electronic S6000",
+		})
+	}
+	return responses
+}
+
+// record collects the events of a tracker
+func record(t *Tracker) *[]Event {
+	var events []Event
+	t.AddHandler(func(ev Event) { events = append(events, ev) })
+	return &events
+}
+
+func eventTypes(events []Event) []EventType {
+	var types []EventType
+	for _, ev := range events {
+		types = append(types, ev.Type)
+	}
+	return types
+}
+
+func TestTrackerComplete(t *testing.T) {
+	tracker := NewTracker()
+	events := record(tracker)
+	for i, r := range responseSet(trackerSerial) {
+		tracker.Push("10.0.0.9", trackerStart.Add(time.Duration(i)*time.Millisecond), r)
+	}
+	tracker.Flush()
+
+	if types := eventTypes(*events); !slices.Equal(types, []EventType{EventFrameAppeared, EventComplete}) {
+		t.Fatalf("unexpected events %v", types)
+	}
+	frames := tracker.Frames()
+	if len(frames) != 1 || !frames[0].Complete() || frames[0].IP != "10.0.0.9" || frames[0].Received != 4 {
+		t.Fatalf("unexpected Mainframes %v", frames)
+	}
+	if !slices.Equal(frames[0].Services, []string{"DISK", "METER", "MIDI", "TEXT"}) {
+		t.Fatalf("unexpected services %v", frames[0].Services)
+	}
+}
+
+func TestTrackerIncomplete(t *testing.T) {
+	responses := responseSet(trackerSerial)
+	tracker := NewTracker()
+	events := record(tracker)
+	tracker.Push("10.0.0.9", trackerStart, responses[0])
+	tracker.Push("10.0.0.9", trackerStart, responses[2])
+
+	//Still within the response timeout
+	tracker.Check(trackerStart.Add(responseTimeout))
+	if types := eventTypes(*events); !slices.Equal(types, []EventType{EventFrameAppeared}) {
+		t.Fatalf("unexpected events %v", types)
+	}
+
+	tracker.Check(trackerStart.Add(responseTimeout + time.Millisecond))
+	tracker.Check(trackerStart.Add(2 * responseTimeout))
+	tracker.Flush()
+	if types := eventTypes(*events); !slices.Equal(types, []EventType{EventFrameAppeared, EventIncomplete}) {
+		t.Fatalf("unexpected events %v", types)
+	}
+	if f := (*events)[1].Frame; f.Complete() || f.Received != 2 || f.Count != 4 {
+		t.Fatalf("unexpected Mainframe %s", f)
+	}
+}
+
+func TestTrackerDuplicates(t *testing.T) {
+	responses := responseSet(trackerSerial)
+	tracker := NewTracker()
+	events := record(tracker)
+
+	//A response seen again starts a new set, the first one is incomplete
+	tracker.Push("10.0.0.9", trackerStart, responses[0])
+	tracker.Push("10.0.0.9", trackerStart, responses[1])
+	for _, r := range responses {
+		tracker.Push("10.0.0.9", trackerStart.Add(time.Second), r)
+	}
+	expected := []EventType{EventFrameAppeared, EventIncomplete, EventComplete}
+	if types := eventTypes(*events); !slices.Equal(types, expected) {
+		t.Fatalf("unexpected events %v", types)
+	}
+
+	//A complete set answered again
+	for _, r := range responses {
+		tracker.Push("10.0.0.9", trackerStart.Add(2*time.Second), r)
+	}
+	tracker.Flush()
+	expected = append(expected, EventComplete)
+	if types := eventTypes(*events); !slices.Equal(types, expected) {
+		t.Fatalf("unexpected events %v", types)
+	}
+	if f := tracker.Frames()[0]; len(f.EntryNames) != 4 || f.Received != 4 {
+		t.Fatalf("unexpected Mainframe %s", f)
+	}
+}
+
+func TestTrackerNoResponseCount(t *testing.T) {
+	r := responseSet(trackerSerial)[0]
+	r.Count = 0
+
+	tracker := NewTracker()
+	events := record(tracker)
+	tracker.Push("10.0.0.9", trackerStart, r)
+	if f := tracker.Frames()[0]; f.Complete() {
+		t.Fatalf("set of 0 responses complete: %s", f)
+	}
+	tracker.Flush()
+	if types := eventTypes(*events); !slices.Equal(types, []EventType{EventFrameAppeared, EventIncomplete}) {
+		t.Fatalf("unexpected events %v", types)
+	}
+	if (Mainframe{}).Complete() {
+		t.Fatal("empty Mainframe complete")
+	}
+}
+
+func TestTrackerFlush(t *testing.T) {
+	tracker := NewTracker()
+	events := record(tracker)
+	for _, serial := range []uint32{3, 1, 2} {
+		r := responseSet(serial)[0]
+		tracker.Push("10.0.0.9", trackerStart, r)
+	}
+	tracker.Flush()
+	tracker.Flush()
+
+	var flushed []uint32
+	for _, ev := range (*events)[3:] {
+		if ev.Type != EventIncomplete {
+			t.Fatalf("unexpected event %s", ev)
+		}
+		flushed = append(flushed, ev.Frame.Serial)
+	}
+	if !slices.Equal(flushed, []uint32{1, 2, 3}) {
+		t.Fatalf("flushed %v, expected each Mainframe once, by serial", flushed)
+	}
+}
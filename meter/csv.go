@@ -0,0 +1,123 @@
+package meter
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"m6kparse/common"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrStreamChanged   = errors.New("frame from another meter stream")
+	ErrChannelsChanged = errors.New("number of channels changed")
+)
+
+// CSVWriter writes the frames of a single meter stream as a time series, one line per frame.
+// The stream and the channel columns are set by the first frame written, frames of
+// another stream or with another number of channels are rejected.
+type CSVWriter struct {
+	w        *csv.Writer
+	stream   common.StreamKey
+	channels int
+	started  bool
+}
+
+func NewCSVWriter(w io.Writer) *CSVWriter {
+	var c CSVWriter
+	c.w = csv.NewWriter(w)
+	return &c
+}
+
+// Write adds a frame, levels are written in dBFS when available
+func (c *CSVWriter) Write(f Frame) error {
+	if !c.started {
+		c.started = true
+		c.stream = f.Stream
+		c.channels = len(f.Levels)
+		header := []string{"timestamp"}
+		for ch := 0; ch < c.channels; ch++ {
+			header = append(header, "ch"+strconv.Itoa(ch+1))
+		}
+		if err := c.w.Write(header); err != nil {
+			return err
+		}
+	}
+	if f.Stream != c.stream {
+		return fmt.Errorf("%w: %s, writing %s", ErrStreamChanged, f.Stream, c.stream)
+	}
+	if len(f.Levels) != c.channels {
+		return fmt.Errorf("%w: %s, %d channels instead of %d", ErrChannelsChanged, f.Stream, len(f.Levels), c.channels)
+	}
+
+	record := []string{f.Timestamp.Format(time.RFC3339Nano)}
+	for _, l := range f.Levels {
+		if l.HasDBFS {
+			record = append(record, strconv.FormatFloat(l.DBFS, 'f', 2, 64))
+		} else {
+			record = append(record, strconv.Itoa(int(l.Raw)))
+		}
+	}
+	return c.w.Write(record)
+}
+
+// Flush writes the buffered lines
+func (c *CSVWriter) Flush() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// CSVFiles writes each meter stream into its own CSV file, named after the stream:
+// meters.csv gets meters_<source>_<destination>.csv files
+type CSVFiles struct {
+	path    string
+	files   map[common.StreamKey]*os.File
+	writers map[common.StreamKey]*CSVWriter
+}
+
+func NewCSVFiles(path string) *CSVFiles {
+	var c CSVFiles
+	c.path = path
+	c.files = make(map[common.StreamKey]*os.File)
+	c.writers = make(map[common.StreamKey]*CSVWriter)
+	return &c
+}
+
+// FileName returns the name of the file of a stream
+func (c *CSVFiles) FileName(key common.StreamKey) string {
+	ext := filepath.Ext(c.path)
+	if ext == "" {
+		ext = ".csv"
+	}
+	endpoint := strings.NewReplacer(":", "-", "[", "", "]", "")
+	return fmt.Sprintf("%s_%s_%s%s", strings.TrimSuffix(c.path, filepath.Ext(c.path)), endpoint.Replace(key.Src), endpoint.Replace(key.Dst), ext)
+}
+
+// Write adds a frame to the file of its stream, the file is created on the first frame
+func (c *CSVFiles) Write(f Frame) error {
+	w, found := c.writers[f.Stream]
+	if !found {
+		file, err := os.Create(c.FileName(f.Stream))
+		if err != nil {
+			return err
+		}
+		w = NewCSVWriter(file)
+		c.files[f.Stream] = file
+		c.writers[f.Stream] = w
+	}
+	return w.Write(f)
+}
+
+// Close flushes and closes all the files
+func (c *CSVFiles) Close() error {
+	var errs []error
+	for key, file := range c.files {
+		errs = append(errs, c.writers[key].Flush(), file.Close())
+	}
+	return errors.Join(errs...)
+}
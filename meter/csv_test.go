@@ -0,0 +1,99 @@
+package meter
+
+import (
+	"errors"
+	"m6kparse/common"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var (
+	streamA = common.StreamKey{Src: "10.0.0.9:5000", Dst: "10.0.0.2:6000"}
+	streamB = common.StreamKey{Src: "10.0.0.9:5000", Dst: "10.0.0.3:6000"}
+	start   = time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+)
+
+func frame(t *testing.T, stream common.StreamKey, layout Layout, seconds int, data ...byte) Frame {
+	t.Helper()
+	levels, err := layout.Decode(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Frame{Stream: stream, Timestamp: start.Add(time.Duration(seconds) * time.Second), Levels: levels}
+}
+
+func TestCSVWriter(t *testing.T) {
+	var out strings.Builder
+	layout := Layout{SampleSize: 2, FullScale: 0x7FFF}
+	w := NewCSVWriter(&out)
+	for i, f := range []Frame{
+		frame(t, streamA, DefaultLayout, 0, 0x00, 0x10, 0x00, 0x20),
+		frame(t, streamA, layout, 1, 0x7F, 0xFF, 0x00, 0x00),
+	} {
+		if err := w.Write(f); err != nil {
+			t.Fatalf("frame %d: %v", i, err)
+		}
+	}
+
+	//Rejected frames are not written
+	if err := w.Write(frame(t, streamA, DefaultLayout, 2, 0x00, 0x10, 0x00, 0x20, 0x00, 0x30)); !errors.Is(err, ErrChannelsChanged) {
+		t.Fatalf("wider frame: error %v, expected ErrChannelsChanged", err)
+	}
+	if err := w.Write(frame(t, streamA, DefaultLayout, 2, 0x00, 0x10)); !errors.Is(err, ErrChannelsChanged) {
+		t.Fatalf("narrower frame: error %v, expected ErrChannelsChanged", err)
+	}
+	if err := w.Write(frame(t, streamB, DefaultLayout, 2, 0x00, 0x10, 0x00, 0x20)); !errors.Is(err, ErrStreamChanged) {
+		t.Fatalf("other stream: error %v, expected ErrStreamChanged", err)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "timestamp,ch1,ch2\n" +
+		"2026-01-02T03:04:05Z,16,32\n" +
+		"2026-01-02T03:04:06Z,0.00,-120.00\n"
+	if out.String() != expected {
+		t.Fatalf("unexpected CSV:\n%s", out.String())
+	}
+}
+
+func TestCSVFiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "meters.csv")
+	c := NewCSVFiles(path)
+	for _, f := range []Frame{
+		frame(t, streamA, DefaultLayout, 0, 0x00, 0x10),
+		frame(t, streamB, DefaultLayout, 0, 0x00, 0x20, 0x00, 0x30),
+		frame(t, streamA, DefaultLayout, 1, 0x00, 0x11),
+	} {
+		if err := c.Write(f); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		stream common.StreamKey
+		file   string
+		data   string
+	}{
+		{streamA, "meters_10.0.0.9-5000_10.0.0.2-6000.csv", "timestamp,ch1\n2026-01-02T03:04:05Z,16\n2026-01-02T03:04:06Z,17\n"},
+		{streamB, "meters_10.0.0.9-5000_10.0.0.3-6000.csv", "timestamp,ch1,ch2\n2026-01-02T03:04:05Z,32,48\n"},
+	}
+	for _, tt := range tests {
+		if name := c.FileName(tt.stream); filepath.Base(name) != tt.file {
+			t.Errorf("%s: file %s, expected %s", tt.stream, name, tt.file)
+		}
+		data, err := os.ReadFile(c.FileName(tt.stream))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != tt.data {
+			t.Errorf("%s: unexpected CSV:\n%s", tt.stream, data)
+		}
+	}
+}
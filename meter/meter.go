@@ -0,0 +1,233 @@
+package meter
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"m6kparse/common"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+After discovery, the Icon sends a "METER PORT" message and the Frame advertises a METER entry.
+The meter stream is assumed to be the UDP traffic sent by the Frame to the port the
+"METER PORT" message was sent from.
+
+The packet format has not been reversed yet: packets are decoded as a sequence of
+linear levels whose location and size are set by a Layout. By default all the packet
+is read as 2 bytes raw values, levels are only converted to dBFS once a full scale
+value is given (see ParseLayout).
+*/
+
+// MinDBFS is the level reported for a zero sample
+const MinDBFS = -120.0
+
+var (
+	ErrTooShort      = errors.New("meter packet too short")
+	ErrInvalidLayout = errors.New("invalid meter layout")
+)
+
+// Layout describes the levels of a meter packet (not confirmed)
+type Layout struct {
+	Offset       int    // Offset of the first level
+	Channels     int    // Number of levels, as many as the packet holds if zero
+	SampleSize   int    // 1 or 2 bytes
+	LittleEndian bool   // For 2 bytes samples
+	FullScale    uint16 // Sample value at 0 dBFS, levels are not converted if zero
+}
+
+var DefaultLayout = Layout{Offset: 0, SampleSize: 2}
+
+// ParseLayout reads a layout from a comma separated list of settings, the settings not
+// given are taken from DefaultLayout:
+//
+//	offset=<n>,channels=<n>,size=<1|2>,le,fullscale=<n>
+//
+// Numbers can be given in hexadecimal (0x7fff).
+func ParseLayout(str string) (Layout, error) {
+	l := DefaultLayout
+	for _, setting := range strings.Split(str, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(setting), "=")
+		if name == "le" && value == "" {
+			l.LittleEndian = true
+			continue
+		}
+		n, err := strconv.ParseUint(value, 0, 16)
+		if err != nil {
+			return Layout{}, fmt.Errorf("%w: %q: %w", ErrInvalidLayout, setting, err)
+		}
+		switch name {
+		case "offset":
+			l.Offset = int(n)
+		case "channels":
+			l.Channels = int(n)
+		case "size":
+			l.SampleSize = int(n)
+		case "fullscale":
+			l.FullScale = uint16(n)
+		default:
+			return Layout{}, fmt.Errorf("%w: unknown setting %q", ErrInvalidLayout, setting)
+		}
+	}
+	if l.SampleSize != 1 && l.SampleSize != 2 {
+		return Layout{}, fmt.Errorf("%w: unsupported sample size %d", ErrInvalidLayout, l.SampleSize)
+	}
+	return l, nil
+}
+
+func (l Layout) String() string {
+	str := fmt.Sprintf("offset=%d,channels=%d,size=%d", l.Offset, l.Channels, l.SampleSize)
+	if l.LittleEndian {
+		str += ",le"
+	}
+	if l.FullScale != 0 {
+		str += fmt.Sprintf(",fullscale=%d", l.FullScale)
+	}
+	return str
+}
+
+// Level is the level of a channel
+type Level struct {
+	Channel int
+	Raw     uint16
+	DBFS    float64 // Set if HasDBFS
+	HasDBFS bool
+}
+
+// Frame holds the levels of all the channels found in a packet
+type Frame struct {
+	Stream    common.StreamKey
+	Timestamp time.Time
+	Levels    []Level
+}
+
+func (f Frame) String() string {
+	str := fmt.Sprintf("%d channels:", len(f.Levels))
+	for _, l := range f.Levels {
+		if l.HasDBFS {
+			str += fmt.Sprintf(" %.1f", l.DBFS)
+		} else {
+			str += fmt.Sprintf(" 0x%x", l.Raw)
+		}
+	}
+	return str
+}
+
+// Decode decodes the levels of a meter packet
+func (l Layout) Decode(data []byte) ([]Level, error) {
+	var levels []Level
+
+	size := l.SampleSize
+	if size != 1 && size != 2 {
+		return nil, fmt.Errorf("unsupported sample size %d", size)
+	}
+	channels := l.Channels
+	if channels == 0 && len(data) > l.Offset {
+		channels = (len(data) - l.Offset) / size
+	}
+	if l.Offset < 0 || len(data) < l.Offset+channels*size || channels == 0 {
+		return nil, fmt.Errorf("%w: %d bytes", ErrTooShort, len(data))
+	}
+
+	for ch := 0; ch < channels; ch++ {
+		offset := l.Offset + ch*size
+		level := Level{Channel: ch, Raw: uint16(data[offset])}
+		if size == 2 && l.LittleEndian {
+			level.Raw = binary.LittleEndian.Uint16(data[offset:])
+		} else if size == 2 {
+			level.Raw = binary.BigEndian.Uint16(data[offset:])
+		}
+		if l.FullScale != 0 {
+			level.DBFS = ToDBFS(level.Raw, l.FullScale)
+			level.HasDBFS = true
+		}
+		levels = append(levels, level)
+	}
+	return levels, nil
+}
+
+// ToDBFS converts a linear level into dBFS, floored at MinDBFS
+func ToDBFS(raw uint16, fullScale uint16) float64 {
+	if raw == 0 {
+		return MinDBFS
+	}
+	return max(20*math.Log10(float64(raw)/float64(fullScale)), MinDBFS)
+}
+
+// Stats holds the statistics of a meter stream
+type Stats struct {
+	Stream   common.StreamKey
+	Packets  int
+	Invalid  int // Packets that could not be decoded
+	Channels int // Channels of the last frame
+	First    time.Time
+	Last     time.Time
+}
+
+func (s Stats) String() string {
+	return fmt.Sprintf("%s: %d packets (%d invalid), %d channels, %v", s.Stream, s.Packets, s.Invalid, s.Channels, s.Last.Sub(s.First))
+}
+
+type Handler func(f Frame)
+
+// Decoder decodes the meter packets of all the streams
+type Decoder struct {
+	layout   Layout
+	stats    map[common.StreamKey]*Stats
+	handlers []Handler
+}
+
+func New(layout Layout) *Decoder {
+	var d Decoder
+	d.layout = layout
+	d.stats = make(map[common.StreamKey]*Stats)
+	return &d
+}
+
+// SetLayout sets the layout used to decode the packets pushed from now on
+func (d *Decoder) SetLayout(layout Layout) {
+	d.layout = layout
+}
+
+// AddHandler registers a function called for each frame decoded
+func (d *Decoder) AddHandler(h Handler) {
+	d.handlers = append(d.handlers, h)
+}
+
+// Push decodes a meter packet
+func (d *Decoder) Push(key common.StreamKey, ts time.Time, data []byte) (Frame, error) {
+	s, found := d.stats[key]
+	if !found {
+		s = &Stats{Stream: key, First: ts}
+		d.stats[key] = s
+	}
+	s.Packets++
+	s.Last = ts
+
+	levels, err := d.layout.Decode(data)
+	if err != nil {
+		s.Invalid++
+		return Frame{}, err
+	}
+	s.Channels = len(levels)
+
+	f := Frame{Stream: key, Timestamp: ts, Levels: levels}
+	for _, h := range d.handlers {
+		h(f)
+	}
+	return f, nil
+}
+
+// Stats returns the statistics of all the streams, sorted by stream
+func (d *Decoder) Stats() []Stats {
+	var stats []Stats
+	for _, s := range d.stats {
+		stats = append(stats, *s)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Stream.String() < stats[j].Stream.String() })
+	return stats
+}
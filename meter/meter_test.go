@@ -0,0 +1,119 @@
+package meter
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestDecode(t *testing.T) {
+	data := []byte{0xAA, 0x7F, 0xFF, 0x00, 0x00, 0x00, 0x01}
+	tests := []struct {
+		name   string
+		layout Layout
+		raw    []uint16
+	}{
+		{"default", DefaultLayout, []uint16{0xAA7F, 0xFF00, 0x0000}},
+		{"offset", Layout{Offset: 1, SampleSize: 2}, []uint16{0x7FFF, 0x0000, 0x0001}},
+		{"little endian", Layout{Offset: 1, SampleSize: 2, LittleEndian: true}, []uint16{0xFF7F, 0x0000, 0x0100}},
+		{"channels", Layout{Offset: 1, Channels: 2, SampleSize: 2}, []uint16{0x7FFF, 0x0000}},
+		{"1 byte", Layout{Offset: 3, SampleSize: 1}, []uint16{0x00, 0x00, 0x00, 0x01}},
+	}
+	for _, tt := range tests {
+		levels, err := tt.layout.Decode(data)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if len(levels) != len(tt.raw) {
+			t.Errorf("%s: %d levels, expected %d", tt.name, len(levels), len(tt.raw))
+			continue
+		}
+		for i, l := range levels {
+			if l.Channel != i || l.Raw != tt.raw[i] || l.HasDBFS {
+				t.Errorf("%s: level %d is %+v, expected raw 0x%x", tt.name, i, l, tt.raw[i])
+			}
+		}
+	}
+}
+
+func TestDecodeDBFS(t *testing.T) {
+	levels, err := Layout{SampleSize: 2, FullScale: 0x7FFF}.Decode([]byte{0x7F, 0xFF, 0x00, 0x00})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !levels[0].HasDBFS || levels[0].DBFS != 0 || !levels[1].HasDBFS || levels[1].DBFS != MinDBFS {
+		t.Fatalf("unexpected levels %+v", levels)
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		layout Layout
+		data   []byte
+	}{
+		{"empty", DefaultLayout, nil},
+		{"offset past the end", Layout{Offset: 4, SampleSize: 2}, []byte{0, 0, 0, 0}},
+		{"missing channels", Layout{Channels: 3, SampleSize: 2}, []byte{0, 0, 0, 0}},
+		{"negative offset", Layout{Offset: -1, Channels: 1, SampleSize: 1}, []byte{0, 0}},
+	}
+	for _, tt := range tests {
+		if _, err := tt.layout.Decode(tt.data); !errors.Is(err, ErrTooShort) {
+			t.Errorf("%s: error %v, expected ErrTooShort", tt.name, err)
+		}
+	}
+	if _, err := (Layout{SampleSize: 3}).Decode([]byte{0, 0, 0}); err == nil {
+		t.Error("3 bytes samples decoded")
+	}
+}
+
+func TestToDBFS(t *testing.T) {
+	tests := []struct {
+		raw       uint16
+		fullScale uint16
+		dbfs      float64
+	}{
+		{0x7FFF, 0x7FFF, 0},
+		{0x4000, 0x8000, -6.02},
+		{0x0800, 0x8000, -24.08},
+		{0xFFFF, 0x7FFF, 6.02},
+		{1, 0xFFFF, -96.33},
+		{0, 0x7FFF, MinDBFS},
+	}
+	for _, tt := range tests {
+		if dbfs := ToDBFS(tt.raw, tt.fullScale); math.Abs(dbfs-tt.dbfs) > 0.01 {
+			t.Errorf("ToDBFS(0x%x, 0x%x) = %.2f, expected %.2f", tt.raw, tt.fullScale, dbfs, tt.dbfs)
+		}
+	}
+}
+
+func TestParseLayout(t *testing.T) {
+	tests := []struct {
+		str    string
+		layout Layout
+	}{
+		{"offset=4", Layout{Offset: 4, SampleSize: 2}},
+		{"offset=0x10,channels=8,size=1", Layout{Offset: 16, Channels: 8, SampleSize: 1}},
+		{"size=2, le, fullscale=0x7fff", Layout{SampleSize: 2, LittleEndian: true, FullScale: 0x7FFF}},
+	}
+	for _, tt := range tests {
+		l, err := ParseLayout(tt.str)
+		if err != nil {
+			t.Errorf("%q: %v", tt.str, err)
+			continue
+		}
+		if l != tt.layout {
+			t.Errorf("%q: %s, expected %s", tt.str, l, tt.layout)
+		}
+		if again, err := ParseLayout(l.String()); err != nil || again != l {
+			t.Errorf("%q: %s does not parse back", tt.str, l)
+		}
+	}
+
+	for _, str := range []string{"", "offset", "offset=x", "size=3", "gain=2", "fullscale=0x10000"} {
+		if _, err := ParseLayout(str); !errors.Is(err, ErrInvalidLayout) {
+			t.Errorf("%q: error %v, expected ErrInvalidLayout", str, err)
+		}
+	}
+}
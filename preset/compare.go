@@ -0,0 +1,74 @@
+package preset
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Difference is a field or a byte range that is not the same in two presets
+type Difference struct {
+	Field  string
+	Offset int // Offset in the PresetData content, for byte ranges
+	Length int // Zero for decoded fields
+	A      string
+	B      string
+}
+
+func (d Difference) String() string {
+	if d.Length == 0 {
+		return fmt.Sprintf("%-10s %s -> %s", d.Field, d.A, d.B)
+	}
+	return fmt.Sprintf("%-10s offset %4d, %3d bytes: %s -> %s", d.Field, d.Offset, d.Length, d.A, d.B)
+}
+
+// Compare lists the decoded fields and the byte ranges of the other regions that differ
+func Compare(a *Preset, b *Preset) []Difference {
+	var diffs []Difference
+
+	if a.Number != b.Number {
+		diffs = append(diffs, Difference{Field: "Number", A: strconv.Itoa(int(a.Number)), B: strconv.Itoa(int(b.Number))})
+	}
+	if a.Header != b.Header {
+		diffs = append(diffs, Difference{Field: "Header", A: fmt.Sprintf("0x%02x", a.Header), B: fmt.Sprintf("0x%02x", b.Header)})
+	}
+	if a.Name != b.Name {
+		diffs = append(diffs, Difference{Field: "Name", A: strconv.Quote(a.Name), B: strconv.Quote(b.Name)})
+	}
+	if a.Crossfeed != b.Crossfeed {
+		diffs = append(diffs, Difference{Field: "Crossfeed", A: strconv.Itoa(int(a.Crossfeed)), B: strconv.Itoa(int(b.Crossfeed))})
+	}
+	diffs = append(diffs, compareBytes("Reserved", reservedOffset, a.Reserved, b.Reserved)...)
+	diffs = append(diffs, compareBytes("Body", bodyOffset, a.Body, b.Body)...)
+	return diffs
+}
+
+// compareBytes returns the ranges of consecutive bytes that differ, extra bytes of the longest slice included
+func compareBytes(field string, offset int, a []byte, b []byte) []Difference {
+	var diffs []Difference
+
+	start := -1
+	for i := 0; i <= max(len(a), len(b)); i++ {
+		same := i < len(a) && i < len(b) && a[i] == b[i]
+		end := i == max(len(a), len(b))
+		if start < 0 && !same && !end {
+			start = i
+		} else if start >= 0 && (same || end) {
+			diffs = append(diffs, Difference{
+				Field:  field,
+				Offset: offset + start,
+				Length: i - start,
+				A:      dumpRange(a, start, i),
+				B:      dumpRange(b, start, i),
+			})
+			start = -1
+		}
+	}
+	return diffs
+}
+
+func dumpRange(data []byte, start int, end int) string {
+	if start >= len(data) {
+		return "(none)"
+	}
+	return fmt.Sprintf("% x", data[start:min(end, len(data))])
+}
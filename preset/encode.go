@@ -0,0 +1,56 @@
+package preset
+
+import (
+	"bytes"
+	"fmt"
+	"m6kparse/codec"
+	"m6kparse/sysex"
+	"strings"
+)
+
+// Encode builds the PresetData message of a preset.
+// The name padding is taken from RawName as long as the name is unchanged.
+func Encode(p *Preset) (*sysex.PresetData, error) {
+	var m sysex.PresetData
+
+	name, err := p.nameBytes()
+	if err != nil {
+		return nil, err
+	}
+	if p.Crossfeed > 0x3FFF {
+		return nil, fmt.Errorf("%w: %d", ErrInvalidCrossfeed, p.Crossfeed)
+	}
+	if len(p.Reserved) != reservedLength {
+		return nil, fmt.Errorf("%w: %d reserved bytes, %d expected", ErrInvalidLength, len(p.Reserved), reservedLength)
+	}
+
+	data := make([]byte, 0, MinSize+len(p.Body))
+	data = append(data, p.Header)
+	data = append(data, codec.EncodeNibbles(name)...)
+	a, b := codec.Encode14(p.Crossfeed)
+	data = append(data, a, b)
+	data = append(data, p.Reserved...)
+	data = append(data, p.Body...)
+	for _, c := range data {
+		if c&0x80 != 0 {
+			return nil, ErrInvalidData
+		}
+	}
+
+	m.DeviceID = p.DeviceID
+	m.Number = p.Number
+	m.Data = data
+	return &m, nil
+}
+
+func (p *Preset) nameBytes() ([]byte, error) {
+	if len(p.Name) > NameLength {
+		return nil, fmt.Errorf("%w: %q, %d characters max", ErrNameTooLong, p.Name, NameLength)
+	}
+	if len(p.RawName) == NameLength && strings.TrimRight(string(p.RawName), "\x00 ") == p.Name {
+		return bytes.Clone(p.RawName), nil
+	}
+	name := make([]byte, NameLength)
+	copy(name, p.Name)
+	return name, nil
+}
@@ -0,0 +1,102 @@
+package preset
+
+import (
+	"encoding/json"
+	"fmt"
+	"m6kparse/sysex"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const IndexFile = "index.json"
+
+// ExportEntry describes an exported preset in the index manifest
+type ExportEntry struct {
+	Number    uint16    `json:"number"`
+	Name      string    `json:"name"`
+	File      string    `json:"file"`
+	Size      int       `json:"size"`
+	Session   int       `json:"session"`
+	Timestamp time.Time `json:"timestamp"`
+	Error     string    `json:"error,omitempty"` // Set if the preset could not be decoded
+}
+
+// Exporter writes the captured presets as .syx files into a directory
+type Exporter struct {
+	dir     string
+	entries map[uint16]*ExportEntry
+}
+
+func NewExporter(dir string) (*Exporter, error) {
+	var e Exporter
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	e.dir = dir
+	e.entries = make(map[uint16]*ExportEntry)
+	return &e, nil
+}
+
+// Add writes a preset as a .syx file, a preset seen again replaces the previous one
+func (e *Exporter) Add(session int, ts time.Time, m *sysex.PresetData) (*ExportEntry, error) {
+	entry := ExportEntry{Number: m.Number, Session: session, Timestamp: ts}
+
+	p, err := Decode(m)
+	if err != nil {
+		entry.Error = err.Error()
+	} else {
+		entry.Name = p.Name
+	}
+	entry.File = FileName(entry.Number, entry.Name)
+
+	data := sysex.Marshal(m)
+	entry.Size = len(data)
+	if err := os.WriteFile(filepath.Join(e.dir, entry.File), data, 0644); err != nil {
+		return nil, err
+	}
+
+	if old, found := e.entries[m.Number]; found && old.File != entry.File {
+		os.Remove(filepath.Join(e.dir, old.File))
+	}
+	e.entries[m.Number] = &entry
+	return &entry, nil
+}
+
+// Entries returns the exported presets sorted by number
+func (e *Exporter) Entries() []ExportEntry {
+	entries := make([]ExportEntry, 0, len(e.entries))
+	for _, entry := range e.entries {
+		entries = append(entries, *entry)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Number < entries[j].Number })
+	return entries
+}
+
+// WriteIndex writes the index manifest of the exported presets
+func (e *Exporter) WriteIndex() error {
+	data, err := json.MarshalIndent(e.Entries(), "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(e.dir, IndexFile), append(data, '\n'), 0644)
+}
+
+// FileName returns the .syx file name of a preset, made of its number and name
+func FileName(number uint16, name string) string {
+	name = strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-':
+			return r
+		}
+		return '_'
+	}, strings.TrimSpace(name))
+
+	if name == "" {
+		return fmt.Sprintf("%03d.syx", number)
+	}
+	return fmt.Sprintf("%03d-%s.syx", number, name)
+}
@@ -0,0 +1,118 @@
+package preset
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"m6kparse/sysex"
+	"strings"
+)
+
+var ErrRoundTrip = errors.New("preset does not encode back to the same bytes")
+
+// Document is the human editable form of a preset.
+// Regions whose meaning is not known are kept as hex blobs.
+type Document struct {
+	Number      uint16 `json:"number"`
+	DeviceID    byte   `json:"device_id"`
+	Header      byte   `json:"header"`
+	Name        string `json:"name"`
+	NamePadding string `json:"name_padding"` // Bytes found after the name, hex
+	Crossfeed   uint16 `json:"crossfeed"`
+	Reserved    Blob   `json:"reserved"`
+	Body        Blob   `json:"body"`
+}
+
+// Blob is a region of the PresetData content, offset is for reference only
+type Blob struct {
+	Offset int    `json:"offset"`
+	Length int    `json:"length"`
+	Data   string `json:"data"`
+}
+
+// MarshalJSON converts a PresetData message into a JSON document.
+// An error is returned if the document would not convert back to the same bytes.
+func MarshalJSON(m *sysex.PresetData) ([]byte, error) {
+	p, err := Decode(m)
+	if err != nil {
+		return nil, err
+	}
+
+	doc := Document{
+		Number:      p.Number,
+		DeviceID:    p.DeviceID,
+		Header:      p.Header,
+		Name:        p.Name,
+		NamePadding: dumpHex(p.RawName[len(p.Name):]),
+		Crossfeed:   p.Crossfeed,
+		Reserved:    Blob{Offset: reservedOffset, Length: len(p.Reserved), Data: dumpHex(p.Reserved)},
+		Body:        Blob{Offset: bodyOffset, Length: len(p.Body), Data: dumpHex(p.Body)},
+	}
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	//Names are not always plain ASCII, make sure nothing is lost
+	decoded, err := UnmarshalJSON(data)
+	if err != nil || !bytes.Equal(sysex.Marshal(decoded), sysex.Marshal(m)) {
+		return nil, fmt.Errorf("preset %d: %w", m.Number, ErrRoundTrip)
+	}
+	return append(data, '\n'), nil
+}
+
+// UnmarshalJSON converts a JSON document back into a PresetData message
+func UnmarshalJSON(data []byte) (*sysex.PresetData, error) {
+	var doc Document
+	var p Preset
+
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	padding, err := parseHex("name_padding", doc.NamePadding)
+	if err != nil {
+		return nil, err
+	}
+	p.Reserved, err = doc.Reserved.bytes("reserved")
+	if err != nil {
+		return nil, err
+	}
+	p.Body, err = doc.Body.bytes("body")
+	if err != nil {
+		return nil, err
+	}
+
+	p.Number = doc.Number
+	p.DeviceID = doc.DeviceID
+	p.Header = doc.Header
+	p.Name = doc.Name
+	p.RawName = append([]byte(doc.Name), padding...)
+	p.Crossfeed = doc.Crossfeed
+	return Encode(&p)
+}
+
+func (b Blob) bytes(field string) ([]byte, error) {
+	data, err := parseHex(field, b.Data)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) != b.Length {
+		return nil, fmt.Errorf("%s: %w: %d bytes, length is %d", field, ErrInvalidLength, len(data), b.Length)
+	}
+	return data, nil
+}
+
+func dumpHex(data []byte) string {
+	return fmt.Sprintf("% x", data)
+}
+
+// parseHex accepts hex bytes, separated by spaces or not
+func parseHex(field string, str string) ([]byte, error) {
+	data, err := hex.DecodeString(strings.Join(strings.Fields(str), ""))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", field, err)
+	}
+	return data, nil
+}
@@ -0,0 +1,149 @@
+package preset
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"m6kparse/sysex"
+	"strings"
+	"testing"
+)
+
+// presetData builds a PresetData message with a padded name and an arbitrary body
+func presetData(name string, padding byte) *sysex.PresetData {
+	data := dump(name, 100)
+	for i := nameOffset + 2*len(name); i < crossfeedOffset; i += 2 {
+		data[i], data[i+1] = padding>>4, padding&0x0F
+	}
+	for i := reservedOffset; i < len(data); i++ {
+		data[i] = byte(i*7) & 0x7F
+	}
+	return &sysex.PresetData{Header: sysex.Header{DeviceID: 3}, Number: 42, Data: data}
+}
+
+func toDocument(t *testing.T, m *sysex.PresetData) Document {
+	t.Helper()
+	data, err := MarshalJSON(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc Document
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatal(err)
+	}
+	return doc
+}
+
+func fromDocument(t *testing.T, doc Document) (*sysex.PresetData, error) {
+	t.Helper()
+	data, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return UnmarshalJSON(data)
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	for _, padding := range []byte{0x00, ' '} {
+		m := presetData("Large Hall", padding)
+		data, err := MarshalJSON(m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		decoded, err := UnmarshalJSON(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(sysex.Marshal(decoded), sysex.Marshal(m)) {
+			t.Fatalf("padding 0x%02x: round trip changed the bytes\n% x\n% x", padding, sysex.Marshal(decoded), sysex.Marshal(m))
+		}
+	}
+}
+
+func TestJSONRename(t *testing.T) {
+	tests := []string{"Hall", "Large Hall Extended", "12345678901234567890", ""}
+	for _, padding := range []byte{0x00, ' '} {
+		m := presetData("Large Hall", padding)
+		for _, name := range tests {
+			doc := toDocument(t, m)
+			doc.Name = name
+			renamed, err := fromDocument(t, doc)
+			if err != nil {
+				t.Fatalf("rename to %q: %v", name, err)
+			}
+			if len(renamed.Data) != len(m.Data) {
+				t.Fatalf("rename to %q: %d bytes, expected %d", name, len(renamed.Data), len(m.Data))
+			}
+			p, err := Decode(renamed)
+			if err != nil {
+				t.Fatalf("rename to %q: %v", name, err)
+			}
+			if p.Name != name {
+				t.Fatalf("renamed to %q, decoded %q", name, p.Name)
+			}
+			//A new name is padded with zeros, nothing else changes
+			if strings.TrimRight(string(p.RawName), "\x00") != name {
+				t.Fatalf("rename to %q: padding not reset, raw name %q", name, p.RawName)
+			}
+			if !bytes.Equal(renamed.Data[crossfeedOffset:], m.Data[crossfeedOffset:]) || renamed.Data[headerOffset] != m.Data[headerOffset] {
+				t.Fatalf("rename to %q changed other fields", name)
+			}
+		}
+	}
+}
+
+func TestJSONNameTooLong(t *testing.T) {
+	doc := toDocument(t, presetData("Hall", 0x00))
+	doc.Name = "This name is too long"
+	if _, err := fromDocument(t, doc); !errors.Is(err, ErrNameTooLong) {
+		t.Fatalf("error %v, expected ErrNameTooLong", err)
+	}
+}
+
+func TestJSONBlobLength(t *testing.T) {
+	doc := toDocument(t, presetData("Hall", 0x00))
+	edited := doc
+	edited.Body.Data += " 01"
+	if _, err := fromDocument(t, edited); !errors.Is(err, ErrInvalidLength) {
+		t.Fatalf("body with an extra byte: error %v, expected ErrInvalidLength", err)
+	}
+
+	edited = doc
+	edited.Reserved.Data = edited.Reserved.Data[3:]
+	if _, err := fromDocument(t, edited); !errors.Is(err, ErrInvalidLength) {
+		t.Fatalf("reserved with a missing byte: error %v, expected ErrInvalidLength", err)
+	}
+
+	//Lengths updated along with the data, reserved bytes have a fixed size
+	edited = doc
+	edited.Reserved.Data = edited.Reserved.Data[3:]
+	edited.Reserved.Length--
+	if _, err := fromDocument(t, edited); !errors.Is(err, ErrInvalidLength) {
+		t.Fatalf("short reserved: error %v, expected ErrInvalidLength", err)
+	}
+
+	edited = doc
+	edited.Body.Data = "zz"
+	if _, err := fromDocument(t, edited); err == nil {
+		t.Fatal("invalid hex accepted")
+	}
+}
+
+func TestJSONEditedBlob(t *testing.T) {
+	m := presetData("Hall", 0x00)
+	doc := toDocument(t, m)
+	doc.Body.Data = "7f" + doc.Body.Data[2:]
+	edited, err := fromDocument(t, doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if edited.Data[bodyOffset] != 0x7F || !bytes.Equal(edited.Data[bodyOffset+1:], m.Data[bodyOffset+1:]) {
+		t.Fatalf("unexpected body: % x", edited.Data[bodyOffset:])
+	}
+
+	//Not a SysEx data byte
+	doc.Body.Data = "80" + doc.Body.Data[2:]
+	if _, err := fromDocument(t, doc); !errors.Is(err, ErrInvalidData) {
+		t.Fatalf("error %v, expected ErrInvalidData", err)
+	}
+}
@@ -0,0 +1,266 @@
+package preset
+
+import (
+	"bytes"
+	"fmt"
+	"m6kparse/sysex"
+	"math"
+	"sort"
+	"strconv"
+)
+
+/*
+Layout discovery: dumps of the same length are aligned and each offset is
+classified from the values seen across dumps. Consecutive offsets of the same
+class are then merged into candidate fields.
+*/
+
+const (
+	maxEnumValues      = 8   // Above this number of distinct values, or if no value repeats, an offset is varying
+	minCorrelation     = 0.9 // Pearson coefficient with the preset number
+	minStringNibbles   = 8   // Shortest nibble string candidate, in bytes
+	minCorrelatedDumps = 3
+)
+
+type OffsetClass int
+
+const (
+	ClassConstant   OffsetClass = iota
+	ClassEnumerated OffsetClass = iota
+	ClassVarying    OffsetClass = iota
+	ClassNumber     OffsetClass = iota // Correlated with the preset number
+	ClassString     OffsetClass = iota // Part of a nibble string
+)
+
+func (c OffsetClass) String() string {
+	switch c {
+	case ClassConstant:
+		return "constant"
+	case ClassEnumerated:
+		return "enumerated"
+	case ClassVarying:
+		return "varying"
+	case ClassNumber:
+		return "preset number"
+	case ClassString:
+		return "nibble string"
+	}
+	return "unknown"
+}
+
+// OffsetStats holds the values seen at an offset of the aligned dumps
+type OffsetStats struct {
+	Offset      int
+	Class       OffsetClass
+	Values      map[byte]int // Number of dumps per value
+	Min         byte
+	Max         byte
+	Correlation float64 // Pearson coefficient with the preset number, zero if too few dumps
+}
+
+// Field is a candidate field made of consecutive offsets of the same class
+type Field struct {
+	Offset int
+	Length int
+	Class  OffsetClass
+	Values []byte // Values seen, for constant and enumerated fields
+}
+
+func (f Field) String() string {
+	str := fmt.Sprintf("offset %4d-%4d %4d bytes %s", f.Offset, f.Offset+f.Length-1, f.Length, f.Class)
+	if len(f.Values) != 0 {
+		str += fmt.Sprintf(" [% x]", f.Values)
+	}
+	return str
+}
+
+// Layout is the analysis of dumps of the same length
+type Layout struct {
+	Size    int // PresetData content size
+	Dumps   int // Number of distinct dumps analysed
+	Numbers []uint16
+	Offsets []OffsetStats
+	Fields  []Field
+}
+
+// Analyze groups the dumps by length and analyses each group, duplicated dumps are ignored.
+// Layouts are sorted by number of dumps, largest first.
+func Analyze(dumps []*sysex.PresetData) []*Layout {
+	groups := make(map[int][]*sysex.PresetData)
+	for _, d := range dumps {
+		duplicate := false
+		for _, g := range groups[len(d.Data)] {
+			if g.Number == d.Number && bytes.Equal(g.Data, d.Data) {
+				duplicate = true
+				break
+			}
+		}
+		if !duplicate {
+			groups[len(d.Data)] = append(groups[len(d.Data)], d)
+		}
+	}
+
+	var layouts []*Layout
+	for size, group := range groups {
+		layouts = append(layouts, analyzeGroup(size, group))
+	}
+	sort.Slice(layouts, func(i, j int) bool {
+		if layouts[i].Dumps != layouts[j].Dumps {
+			return layouts[i].Dumps > layouts[j].Dumps
+		}
+		return layouts[i].Size < layouts[j].Size
+	})
+	return layouts
+}
+
+func analyzeGroup(size int, group []*sysex.PresetData) *Layout {
+	var l Layout
+
+	l.Size = size
+	l.Dumps = len(group)
+	numbers := make([]float64, len(group))
+	for i, d := range group {
+		l.Numbers = append(l.Numbers, d.Number)
+		numbers[i] = float64(d.Number)
+	}
+
+	l.Offsets = make([]OffsetStats, size)
+	for offset := 0; offset < size; offset++ {
+		s := OffsetStats{Offset: offset, Values: make(map[byte]int), Min: 0xFF}
+		values := make([]float64, len(group))
+		for i, d := range group {
+			v := d.Data[offset]
+			s.Values[v]++
+			s.Min = min(s.Min, v)
+			s.Max = max(s.Max, v)
+			values[i] = float64(v)
+		}
+
+		if len(group) >= minCorrelatedDumps {
+			s.Correlation = pearson(numbers, values)
+		}
+
+		switch {
+		case len(s.Values) == 1:
+			s.Class = ClassConstant
+		case math.Abs(s.Correlation) >= minCorrelation:
+			s.Class = ClassNumber
+		case len(s.Values) <= maxEnumValues && len(s.Values) < len(group):
+			s.Class = ClassEnumerated
+		default:
+			s.Class = ClassVarying
+		}
+		l.Offsets[offset] = s
+	}
+
+	markStrings(&l, group)
+	l.Fields = fields(l.Offsets)
+	return &l
+}
+
+// markStrings flags the runs of nibble pairs decoding to text in all dumps.
+// Runs are searched from both alignments, the longest ones win.
+func markStrings(l *Layout, group []*sysex.PresetData) {
+	isText := func(offset int) bool {
+		for _, d := range group {
+			a, b := d.Data[offset], d.Data[offset+1]
+			if a > 0x0F || b > 0x0F {
+				return false
+			}
+			c := a<<4 | b
+			if c != 0 && !strconv.IsPrint(rune(c)) {
+				return false
+			}
+		}
+		return true
+	}
+	//At least one dump must have text, not only padding
+	hasText := func(start int, end int) bool {
+		for _, d := range group {
+			for i := start; i < end; i += 2 {
+				if d.Data[i] != 0 || d.Data[i+1] != 0 {
+					return true
+				}
+			}
+		}
+		return false
+	}
+
+	for offset := 0; offset+1 < l.Size; {
+		if l.Offsets[offset].Class == ClassString || !isText(offset) {
+			offset++
+			continue
+		}
+		end := offset
+		for end+1 < l.Size && isText(end) {
+			end += 2
+		}
+		if end-offset >= minStringNibbles && hasText(offset, end) {
+			for i := offset; i < end; i++ {
+				l.Offsets[i].Class = ClassString
+			}
+			offset = end
+		} else {
+			offset++
+		}
+	}
+}
+
+// fields merges consecutive offsets of the same class.
+// Constant and enumerated offsets are kept separate as each one may be a field.
+func fields(offsets []OffsetStats) []Field {
+	var result []Field
+
+	for _, s := range offsets {
+		n := len(result)
+		mergeable := s.Class == ClassString || s.Class == ClassVarying || s.Class == ClassConstant
+		if n != 0 && mergeable && result[n-1].Class == s.Class && result[n-1].Offset+result[n-1].Length == s.Offset {
+			if s.Class != ClassConstant || bytes.Equal(result[n-1].Values, sortedValues(s.Values)) {
+				result[n-1].Length++
+				continue
+			}
+		}
+
+		f := Field{Offset: s.Offset, Length: 1, Class: s.Class}
+		if s.Class == ClassConstant || s.Class == ClassEnumerated {
+			f.Values = sortedValues(s.Values)
+		}
+		result = append(result, f)
+	}
+	return result
+}
+
+func sortedValues(values map[byte]int) []byte {
+	sorted := make([]byte, 0, len(values))
+	for v := range values {
+		sorted = append(sorted, v)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return sorted
+}
+
+// pearson returns the correlation coefficient of two series, zero if one of them is constant
+func pearson(x []float64, y []float64) float64 {
+	var sx, sy, sxx, syy, sxy float64
+	n := float64(len(x))
+	for i := range x {
+		sx += x[i]
+		sy += y[i]
+		sxx += x[i] * x[i]
+		syy += y[i] * y[i]
+		sxy += x[i] * y[i]
+	}
+	den := math.Sqrt(n*sxx-sx*sx) * math.Sqrt(n*syy-sy*sy)
+	if den == 0 {
+		return 0
+	}
+	return (n*sxy - sx*sy) / den
+}
+
+func (l *Layout) String() string {
+	str := fmt.Sprintf("PresetData content of %d bytes, %d dumps, presets %v\n", l.Size, l.Dumps, l.Numbers)
+	for _, f := range l.Fields {
+		str += f.String() + "\n"
+	}
+	return str
+}
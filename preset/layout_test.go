@@ -0,0 +1,96 @@
+package preset
+
+import (
+	"m6kparse/codec"
+	"m6kparse/sysex"
+	"slices"
+	"testing"
+)
+
+// layoutDump builds a 20 bytes dump: a constant byte, an enumerated byte, the preset
+// number, a name of 8 characters as nibbles and a varying byte
+func layoutDump(number uint16, name string) *sysex.PresetData {
+	raw := make([]byte, 8)
+	copy(raw, name)
+	data := []byte{0x00, byte(number % 2), byte(number)}
+	data = append(data, codec.EncodeNibbles(raw)...)
+	data = append(data, byte(number*37)&0x7F)
+	return &sysex.PresetData{Number: number, Data: data}
+}
+
+func TestAnalyzeClasses(t *testing.T) {
+	names := []string{"Hall", "Room", "Plate", "Chamber", "Church", "Spring"}
+	var dumps []*sysex.PresetData
+	for i, name := range names {
+		dumps = append(dumps, layoutDump(uint16(10+i), name))
+	}
+
+	layouts := Analyze(dumps)
+	if len(layouts) != 1 {
+		t.Fatalf("%d layouts, expected 1", len(layouts))
+	}
+	l := layouts[0]
+	if l.Size != 20 || l.Dumps != len(names) {
+		t.Fatalf("unexpected layout: %d bytes, %d dumps", l.Size, l.Dumps)
+	}
+
+	tests := []struct {
+		offset int
+		class  OffsetClass
+	}{
+		{0, ClassConstant},
+		{1, ClassEnumerated},
+		{2, ClassNumber},
+		{3, ClassString},
+		{18, ClassString},
+		{19, ClassVarying},
+	}
+	for _, tt := range tests {
+		if c := l.Offsets[tt.offset].Class; c != tt.class {
+			t.Errorf("offset %d: %s, expected %s", tt.offset, c, tt.class)
+		}
+	}
+
+	expected := []Field{
+		{Offset: 0, Length: 1, Class: ClassConstant, Values: []byte{0x00}},
+		{Offset: 1, Length: 1, Class: ClassEnumerated, Values: []byte{0x00, 0x01}},
+		{Offset: 2, Length: 1, Class: ClassNumber},
+		{Offset: 3, Length: 16, Class: ClassString},
+		{Offset: 19, Length: 1, Class: ClassVarying},
+	}
+	if len(l.Fields) != len(expected) {
+		t.Fatalf("fields:\n%s", l)
+	}
+	for i, f := range l.Fields {
+		e := expected[i]
+		if f.Offset != e.Offset || f.Length != e.Length || f.Class != e.Class || !slices.Equal(f.Values, e.Values) {
+			t.Errorf("field %d: %s, expected %s", i, f, e)
+		}
+	}
+}
+
+func TestAnalyzeGroups(t *testing.T) {
+	short := layoutDump(1, "Hall")
+	long := layoutDump(2, "Room")
+	long.Data = append(long.Data, 0x00)
+
+	//Duplicated dumps are counted once
+	layouts := Analyze([]*sysex.PresetData{short, long, short, layoutDump(3, "Plate")})
+	if len(layouts) != 2 {
+		t.Fatalf("%d layouts, expected 2", len(layouts))
+	}
+	if layouts[0].Size != 20 || layouts[0].Dumps != 2 || !slices.Equal(layouts[0].Numbers, []uint16{1, 3}) {
+		t.Fatalf("unexpected first layout: %d bytes, presets %v", layouts[0].Size, layouts[0].Numbers)
+	}
+	if layouts[1].Size != 21 || layouts[1].Dumps != 1 {
+		t.Fatalf("unexpected second layout: %d bytes, %d dumps", layouts[1].Size, layouts[1].Dumps)
+	}
+}
+
+func TestAnalyzeFewDumps(t *testing.T) {
+	//Too few dumps to correlate with the preset number
+	l := Analyze([]*sysex.PresetData{layoutDump(1, "Hall"), layoutDump(2, "Room")})[0]
+	if l.Offsets[2].Correlation != 0 || l.Offsets[2].Class == ClassNumber {
+		t.Fatalf("offset 2 classified from 2 dumps: %s", l.Offsets[2].Class)
+	}
+}
@@ -0,0 +1,40 @@
+package preset
+
+import (
+	"errors"
+	"fmt"
+	"m6kparse/sysex"
+	"os"
+)
+
+var ErrNotPreset = errors.New("not a PresetData message")
+
+// Load reads a .syx preset file and validates it against the PresetData layout
+func Load(path string) (*sysex.PresetData, *Preset, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	m, p, err := Unmarshal(data)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return m, p, nil
+}
+
+// Unmarshal decodes a complete PresetData SysEx message (F0 ... F7)
+func Unmarshal(data []byte) (*sysex.PresetData, *Preset, error) {
+	msg, err := sysex.Unmarshal(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	m, ok := msg.(*sysex.PresetData)
+	if !ok {
+		return nil, nil, fmt.Errorf("%w: %s", ErrNotPreset, msg.String())
+	}
+	p, err := Decode(m)
+	if err != nil {
+		return nil, nil, err
+	}
+	return m, p, nil
+}
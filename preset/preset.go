@@ -0,0 +1,122 @@
+package preset
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"m6kparse/codec"
+	"m6kparse/sysex"
+	"strings"
+)
+
+/*
+PresetData content, after the preset number:
+
+ Byte 0 : Header (usually 0x00)
+ Byte 1-40 : Preset name, 20 characters as nibbles, padded with 0x00
+ Byte 41-42 : Crossfeed
+ Byte 43-56 : Reserved
+ Byte 57.. : Preset body, not identified yet
+
+A dump can hold more than one preset, their size is not fixed: only the identified part
+is checked (MinSize), everything after the reserved bytes is left in the body.
+*/
+
+const (
+	headerOffset    = 0
+	nameOffset      = 1
+	NameLength      = 20
+	crossfeedOffset = nameOffset + 2*NameLength
+	reservedOffset  = crossfeedOffset + 2
+	reservedLength  = 14
+	bodyOffset      = reservedOffset + reservedLength
+
+	// MinSize is the size of the identified part of a preset
+	MinSize = bodyOffset
+)
+
+var (
+	ErrInvalidLength    = errors.New("unexpected preset length")
+	ErrInvalidName      = errors.New("invalid preset name encoding")
+	ErrNameTooLong      = errors.New("preset name too long")
+	ErrInvalidCrossfeed = errors.New("crossfeed out of range")
+	ErrInvalidData      = errors.New("preset data bytes must be 7 bits")
+)
+
+// Region is a part of the preset data
+type Region struct {
+	Name       string
+	Offset     int // Offset in the PresetData content, preset number excluded
+	Data       []byte
+	Identified bool // Set if the meaning of the region is known
+}
+
+func (r Region) String() string {
+	state := "unidentified"
+	if r.Identified {
+		state = "identified"
+	}
+	return fmt.Sprintf("%-10s offset %4d, %4d bytes (%s)", r.Name, r.Offset, len(r.Data), state)
+}
+
+type Preset struct {
+	DeviceID  byte // SysEx device ID of the message
+	Number    uint16
+	Header    byte
+	Name      string // Padding removed
+	RawName   []byte // Decoded name, padding included
+	Crossfeed uint16
+	Reserved  []byte
+	Body      []byte
+	Regions   []Region
+}
+
+// Decode decodes the content of a PresetData message
+func Decode(m *sysex.PresetData) (*Preset, error) {
+	var p Preset
+	data := m.Data
+
+	if len(data) < MinSize {
+		return nil, fmt.Errorf("preset %d: %w: %d bytes, at least %d expected", m.Number, ErrInvalidLength, len(data), MinSize)
+	}
+
+	rawName, err := codec.DecodeNibbles(data[nameOffset:crossfeedOffset])
+	if err != nil {
+		return nil, fmt.Errorf("preset %d: %w: %w", m.Number, ErrInvalidName, err)
+	}
+
+	p.DeviceID = m.DeviceID
+	p.Number = m.Number
+	p.Header = data[headerOffset]
+	p.RawName = rawName
+	p.Name = strings.TrimRight(string(rawName), "\x00 ")
+	p.Crossfeed = codec.Decode14(data[crossfeedOffset], data[crossfeedOffset+1])
+	p.Reserved = bytes.Clone(data[reservedOffset:bodyOffset])
+	p.Body = bytes.Clone(data[bodyOffset:])
+
+	p.Regions = []Region{
+		{Name: "Header", Offset: headerOffset, Data: bytes.Clone(data[headerOffset:nameOffset]), Identified: true},
+		{Name: "Name", Offset: nameOffset, Data: bytes.Clone(data[nameOffset:crossfeedOffset]), Identified: true},
+		{Name: "Crossfeed", Offset: crossfeedOffset, Data: bytes.Clone(data[crossfeedOffset:reservedOffset]), Identified: true},
+		{Name: "Reserved", Offset: reservedOffset, Data: bytes.Clone(p.Reserved), Identified: true},
+	}
+	if len(p.Body) != 0 {
+		p.Regions = append(p.Regions, Region{Name: "Body", Offset: bodyOffset, Data: bytes.Clone(p.Body)})
+	}
+	return &p, nil
+}
+
+func (p *Preset) String() string {
+	return fmt.Sprintf("Preset %d '%s' (header 0x%02x, crossfeed %d, %d bytes unidentified)", p.Number, p.Name, p.Header, p.Crossfeed, p.Unidentified())
+}
+
+// Unidentified returns the number of bytes whose meaning is not known
+func (p *Preset) Unidentified() int {
+	var count int
+	for _, r := range p.Regions {
+		if !r.Identified {
+			count += len(r.Data)
+		}
+	}
+	return count
+}
@@ -0,0 +1,66 @@
+package preset
+
+import (
+	"errors"
+	"m6kparse/codec"
+	"m6kparse/sysex"
+	"testing"
+)
+
+// dump builds a PresetData content of the given size
+func dump(name string, size int) []byte {
+	raw := make([]byte, NameLength)
+	copy(raw, name)
+	data := []byte{0x00}
+	data = append(data, codec.EncodeNibbles(raw)...)
+	data = append(data, 0x00, 0x40)
+	data = append(data, make([]byte, size-len(data))...)
+	return data
+}
+
+func TestDecode(t *testing.T) {
+	p, err := Decode(&sysex.PresetData{Number: 12, Data: dump("Hall", 100)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Number != 12 || p.Name != "Hall" || p.Crossfeed != 0x40 || len(p.Body) != 100-MinSize {
+		t.Fatalf("unexpected preset: %s", p)
+	}
+	if p.Unidentified() != 100-MinSize {
+		t.Fatalf("%d bytes unidentified, expected %d", p.Unidentified(), 100-MinSize)
+	}
+}
+
+func TestDecodeSizes(t *testing.T) {
+	tests := []struct {
+		size int
+		err  error
+	}{
+		{0, ErrInvalidLength},
+		{MinSize - 1, ErrInvalidLength},
+		{MinSize, nil},
+		{101, nil},
+		{340, nil},
+	}
+	for _, tt := range tests {
+		data := dump("Hall", max(tt.size, MinSize))[:tt.size]
+		p, err := Decode(&sysex.PresetData{Data: data})
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%d bytes: error %v, expected %v", tt.size, err, tt.err)
+		}
+		if (p != nil) != (tt.err == nil) {
+			t.Errorf("%d bytes: preset returned %v along with error %v", tt.size, p != nil, err)
+		}
+		if p != nil && len(p.Body) != tt.size-MinSize {
+			t.Errorf("%d bytes: %d bytes of body, expected %d", tt.size, len(p.Body), tt.size-MinSize)
+		}
+	}
+}
+
+func TestDecodeInvalidName(t *testing.T) {
+	data := dump("Hall", 100)
+	data[nameOffset] = 0x10
+	if _, err := Decode(&sysex.PresetData{Data: data}); !errors.Is(err, ErrInvalidName) {
+		t.Fatalf("error %v, expected ErrInvalidName", err)
+	}
+}
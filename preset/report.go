@@ -0,0 +1,108 @@
+package preset
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"m6kparse/correlator"
+	"m6kparse/sysex"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// ReportEntry is a preset requested or received during a capture.
+// The engine a preset was fetched for is not reported: the PresetRequest byte holding it
+// is not identified, it is expected among the request bytes left in RequestExtra.
+type ReportEntry struct {
+	Number       uint16    `json:"number"`
+	Name         string    `json:"name"`
+	Size         int       `json:"size"` // PresetData content size, zero if not received
+	Session      int       `json:"session"`
+	Timestamp    time.Time `json:"timestamp"`     // Request time, or response time if unsolicited
+	RequestExtra string    `json:"request_extra"` // Unidentified PresetRequest bytes
+	Answered     bool      `json:"answered"`
+	Unsolicited  bool      `json:"unsolicited"` // PresetData received without request
+	Error        string    `json:"error,omitempty"`
+}
+
+// Report lists the presets requested and received, from the pairing of a capture
+func Report(c *correlator.Correlator) []ReportEntry {
+	var entries []ReportEntry
+
+	for _, ex := range c.Exchanges() {
+		req, ok := ex.Request.Message.(*sysex.PresetRequest)
+		if !ok {
+			continue
+		}
+		e := newReportEntry(ex.Request, req.Number)
+		e.RequestExtra = fmt.Sprintf("% x", req.Extra)
+		e.Answered = true
+		e.setData(ex.Response.Message.(*sysex.PresetData))
+		entries = append(entries, e)
+	}
+	for _, u := range c.Unanswered() {
+		if req, ok := u.Message.(*sysex.PresetRequest); ok {
+			e := newReportEntry(u, req.Number)
+			e.RequestExtra = fmt.Sprintf("% x", req.Extra)
+			entries = append(entries, e)
+		}
+	}
+	for _, u := range c.Unsolicited() {
+		if data, ok := u.Message.(*sysex.PresetData); ok {
+			e := newReportEntry(u, data.Number)
+			e.Unsolicited = true
+			e.setData(data)
+			entries = append(entries, e)
+		}
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Timestamp.Before(entries[j].Timestamp) })
+	return entries
+}
+
+func newReportEntry(e correlator.Entry, number uint16) ReportEntry {
+	return ReportEntry{Number: number, Session: e.Session, Timestamp: e.Timestamp}
+}
+
+func (e *ReportEntry) setData(m *sysex.PresetData) {
+	e.Size = len(m.Data)
+	p, err := Decode(m)
+	if err != nil {
+		e.Error = err.Error()
+		return
+	}
+	e.Name = p.Name
+}
+
+// WriteReportJSON writes the report entries as a JSON array
+func WriteReportJSON(w io.Writer, entries []ReportEntry) error {
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// WriteReportCSV writes the report entries as CSV, with a header line
+func WriteReportCSV(w io.Writer, entries []ReportEntry) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"number", "name", "size", "session", "timestamp", "request_extra", "answered", "unsolicited", "error"})
+	for _, e := range entries {
+		cw.Write([]string{
+			strconv.Itoa(int(e.Number)),
+			e.Name,
+			strconv.Itoa(e.Size),
+			strconv.Itoa(e.Session),
+			e.Timestamp.Format(time.RFC3339Nano),
+			e.RequestExtra,
+			strconv.FormatBool(e.Answered),
+			strconv.FormatBool(e.Unsolicited),
+			e.Error,
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,273 @@
+package roles
+
+import (
+	"m6kparse/common"
+	"m6kparse/discovery"
+	"m6kparse/sysex"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+/*
+The Icon and Frame IP addresses are inferred from the traffic:
+
+ - The "TCIcon" discovery probe is sent by the Icon
+ - Discovery responses are sent by a Frame to the Icon
+ - The Frame is the server side of the TCP port 1026 connection
+ - SysEx message types are only sent in one direction (see sysex registry)
+ - A device keeps its MAC address when its IP address changes (DHCP), on the same LAN
+
+Addresses given on the command line are never changed. Addresses found from the TCP
+connection or from the probe are never replaced by a MAC address hint: a MAC address
+may be shared by several IPs (router, several addresses on one interface).
+*/
+
+const m6000Port = 1026
+
+type Source int
+
+const (
+	SourceUnknown   Source = iota
+	SourceOverride  Source = iota // Given by the user
+	SourceProbe     Source = iota // Sender of a discovery probe
+	SourceDiscovery Source = iota // Discovery response
+	SourceTCP       Source = iota // TCP port 1026 connection
+	SourceSysEx     Source = iota // Direction of a known SysEx message type
+	SourceMAC       Source = iota // MAC address of the device seen before
+)
+
+func (s Source) String() string {
+	switch s {
+	case SourceOverride:
+		return "command line"
+	case SourceProbe:
+		return "discovery probe"
+	case SourceDiscovery:
+		return "discovery response"
+	case SourceTCP:
+		return "TCP port 1026"
+	case SourceSysEx:
+		return "SysEx direction"
+	case SourceMAC:
+		return "MAC address"
+	}
+	return "unknown"
+}
+
+// Role is the address of the Icon or of the Frame
+type Role struct {
+	Name   string // Icon or Frame
+	IP     string
+	MAC    string // Empty if not seen
+	Source Source
+}
+
+func (r Role) Known() bool {
+	return r.IP != ""
+}
+
+func (r Role) String() string {
+	if !r.Known() {
+		return r.Name + " not identified"
+	}
+	str := r.Name + " " + r.IP
+	if r.MAC != "" {
+		str += " (" + r.MAC + ")"
+	}
+	return str + " from " + r.Source.String()
+}
+
+type Handler func(r Role)
+
+// Detector infers the Icon and Frame addresses
+type Detector struct {
+	icon     Role
+	frame    Role
+	macs     map[string]string          // Last MAC address seen for an IP
+	macIPs   map[string]map[string]bool // IPs seen for a MAC address
+	active   map[string]bool            // IPs seen in the discovery or port 1026 traffic
+	handlers []Handler
+}
+
+// New creates a Detector, an empty (or "auto") address is detected from the traffic
+func New(iconIP string, frameIP string) *Detector {
+	var d Detector
+
+	d.icon = Role{Name: "Icon"}
+	d.frame = Role{Name: "Frame"}
+	if iconIP != "" && iconIP != "auto" {
+		d.icon.IP = iconIP
+		d.icon.Source = SourceOverride
+	}
+	if frameIP != "" && frameIP != "auto" {
+		d.frame.IP = frameIP
+		d.frame.Source = SourceOverride
+	}
+	d.macs = make(map[string]string)
+	d.macIPs = make(map[string]map[string]bool)
+	d.active = make(map[string]bool)
+	return &d
+}
+
+// AddHandler registers a function called when the address of a role changes
+func (d *Detector) AddHandler(h Handler) {
+	d.handlers = append(d.handlers, h)
+}
+
+func (d *Detector) Icon() Role {
+	return d.icon
+}
+
+func (d *Detector) Frame() Role {
+	return d.frame
+}
+
+func (d *Detector) IconIP() string {
+	return d.icon.IP
+}
+
+func (d *Detector) FrameIP() string {
+	return d.frame.IP
+}
+
+// IsIconFrame returns true if the addresses are those of the Icon and of the Frame, in any order
+func (d *Detector) IsIconFrame(src string, dst string) bool {
+	if !d.icon.Known() || !d.frame.Known() {
+		return false
+	}
+	return (src == d.icon.IP && dst == d.frame.IP) || (src == d.frame.IP && dst == d.icon.IP)
+}
+
+// Observe looks for role hints in a captured packet
+func (d *Detector) Observe(packet gopacket.Packet) {
+	ipLayer, _ := packet.Layer(layers.LayerTypeIPv4).(*layers.IPv4)
+	if ipLayer == nil {
+		return
+	}
+	src := ipLayer.SrcIP.String()
+	dst := ipLayer.DstIP.String()
+
+	if tcp, ok := packet.Layer(layers.LayerTypeTCP).(*layers.TCP); ok {
+		d.ObserveTCP(src, dst, uint16(tcp.SrcPort), uint16(tcp.DstPort))
+	}
+	if udp, ok := packet.Layer(layers.LayerTypeUDP).(*layers.UDP); ok {
+		d.ObserveDiscovery(src, dst, udp.Payload)
+	}
+	//Last, a role may follow its MAC address to an IP seen in this packet
+	if eth, ok := packet.Layer(layers.LayerTypeEthernet).(*layers.Ethernet); ok {
+		d.ObserveMAC(src, eth.SrcMAC.String())
+		d.ObserveMAC(dst, eth.DstMAC.String())
+	}
+}
+
+// ObserveDiscovery uses the discovery probes and responses
+func (d *Detector) ObserveDiscovery(src string, dst string, payload []byte) {
+	m, err := discovery.Decode(payload)
+	if err != nil {
+		return
+	}
+	switch m.(type) {
+	case *discovery.Probe:
+		//Any host may probe (m6kdiscover), the TCP connection tells which one is the Icon
+		d.active[src] = true
+		d.setIfUnknown(&d.icon, src, SourceProbe)
+	case *discovery.FrameResponse:
+		d.active[src] = true
+		d.setIfUnknown(&d.icon, dst, SourceDiscovery)
+		//Several Frames may answer, the TCP connection tells which one is used
+		d.setIfUnknown(&d.frame, src, SourceDiscovery)
+	}
+}
+
+// ObserveTCP uses the server side of the TCP port 1026 connection
+func (d *Detector) ObserveTCP(src string, dst string, srcPort uint16, dstPort uint16) {
+	if srcPort == m6000Port || dstPort == m6000Port {
+		d.active[src] = true
+		d.active[dst] = true
+	}
+	switch {
+	case dstPort == m6000Port && srcPort != m6000Port:
+		d.setConnection(src, dst)
+	case srcPort == m6000Port && dstPort != m6000Port:
+		d.setConnection(dst, src)
+	}
+}
+
+// ObserveSysEx uses the direction of a SysEx message type, for callers without TCP ports
+func (d *Detector) ObserveSysEx(src string, dst string, data []byte) {
+	m, err := sysex.Unmarshal(data)
+	if m == nil || err != nil {
+		return
+	}
+	info, found := sysex.Lookup(m.Type())
+	if !found || m.SysExHeader().Profile != sysex.ProfileM6000 {
+		return
+	}
+	d.active[src] = true
+	d.active[dst] = true
+	if info.Direction == common.IconToFrame {
+		d.setIfUnknown(&d.icon, src, SourceSysEx)
+		d.setIfUnknown(&d.frame, dst, SourceSysEx)
+	} else {
+		d.setIfUnknown(&d.icon, dst, SourceSysEx)
+		d.setIfUnknown(&d.frame, src, SourceSysEx)
+	}
+}
+
+// ObserveMAC follows a device whose IP address changed.
+// Only roles found from the discovery responses or the SysEx directions follow their MAC
+// address, and only to an IP seen in the discovery or port 1026 traffic: a role moves
+// once at most, it does not flip between IPs sharing a MAC address.
+func (d *Detector) ObserveMAC(ip string, mac string) {
+	if ip == "" || mac == "" || mac == "ff:ff:ff:ff:ff:ff" {
+		return
+	}
+	d.macs[ip] = mac
+	if d.macIPs[mac] == nil {
+		d.macIPs[mac] = make(map[string]bool)
+	}
+	d.macIPs[mac][ip] = true
+
+	//A MAC address used by many IPs is a router, not a device
+	if len(d.macIPs[mac]) > 2 || (d.icon.MAC != "" && d.icon.MAC == d.frame.MAC) {
+		return
+	}
+	for _, r := range []*Role{&d.icon, &d.frame} {
+		if r.IP == ip && r.MAC == "" {
+			r.MAC = mac
+		} else if r.MAC == mac && r.IP != ip && d.canFollowMAC(r, ip) {
+			d.set(r, ip, SourceMAC)
+		}
+	}
+}
+
+func (d *Detector) canFollowMAC(r *Role, ip string) bool {
+	return (r.Source == SourceDiscovery || r.Source == SourceSysEx) && d.active[ip]
+}
+
+// setConnection uses the first port 1026 connection seen, it replaces the weaker hints
+func (d *Detector) setConnection(client string, server string) {
+	if d.icon.IP == client || (d.icon.Source != SourceTCP && d.icon.Source != SourceOverride) {
+		d.set(&d.icon, client, SourceTCP)
+		d.set(&d.frame, server, SourceTCP)
+	}
+}
+
+func (d *Detector) setIfUnknown(r *Role, ip string, source Source) {
+	if !r.Known() {
+		d.set(r, ip, source)
+	}
+}
+
+func (d *Detector) set(r *Role, ip string, source Source) {
+	if r.Source == SourceOverride || r.IP == ip {
+		return
+	}
+	r.IP = ip
+	r.MAC = d.macs[ip]
+	r.Source = source
+	for _, h := range d.handlers {
+		h(*r)
+	}
+}
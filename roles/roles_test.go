@@ -0,0 +1,170 @@
+package roles
+
+import (
+	"m6kparse/discovery"
+	"testing"
+)
+
+const (
+	iconMAC  = "00:11:22:33:44:01"
+	frameMAC = "00:11:22:33:44:02"
+)
+
+func frameResponse(t *testing.T) []byte {
+	t.Helper()
+	data, err := discovery.NewResponseSet(112255, discovery.DefaultDeviceName, discovery.EntryNames(112255))[0].Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestTCPRoleNotMovedByMAC(t *testing.T) {
+	d := New("", "")
+	d.ObserveMAC("10.0.0.2", iconMAC)
+	d.ObserveMAC("10.0.0.9", frameMAC)
+	d.ObserveTCP("10.0.0.2", "10.0.0.9", 50000, 1026)
+	if d.FrameIP() != "10.0.0.9" || d.Frame().Source != SourceTCP || d.Frame().MAC != frameMAC {
+		t.Fatalf("unexpected Frame: %s", d.Frame())
+	}
+
+	//Link local address of the Frame, then traffic from both IPs
+	for i := 0; i < 3; i++ {
+		d.ObserveMAC("169.254.3.4", frameMAC)
+		d.ObserveMAC("10.0.0.9", frameMAC)
+	}
+	if d.FrameIP() != "10.0.0.9" || d.Frame().Source != SourceTCP {
+		t.Fatalf("Frame moved: %s", d.Frame())
+	}
+	if !d.IsIconFrame("10.0.0.2", "10.0.0.9") {
+		t.Fatal("port 1026 traffic not identified")
+	}
+}
+
+func TestProbeRoleNotMovedByMAC(t *testing.T) {
+	probe, err := (&discovery.Probe{}).Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := New("", "")
+	d.ObserveMAC("10.0.0.2", iconMAC)
+	d.ObserveDiscovery("10.0.0.2", "10.0.0.255", probe)
+	if d.IconIP() != "10.0.0.2" || d.Icon().Source != SourceProbe {
+		t.Fatalf("unexpected Icon: %s", d.Icon())
+	}
+
+	//Even if the other IP takes part in the discovery
+	d.ObserveDiscovery("10.0.0.3", "10.0.0.255", probe)
+	d.ObserveMAC("10.0.0.3", iconMAC)
+	d.ObserveDiscovery("10.0.0.2", "10.0.0.255", probe)
+	d.ObserveMAC("10.0.0.2", iconMAC)
+	if d.IconIP() != "10.0.0.2" {
+		t.Fatalf("unexpected Icon: %s", d.Icon())
+	}
+}
+
+func TestProbeDoesNotReplaceTCP(t *testing.T) {
+	probe, err := (&discovery.Probe{}).Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//m6kdiscover run from a laptop during the capture
+	d := New("", "")
+	d.ObserveTCP("10.0.0.2", "10.0.0.9", 50000, 1026)
+	d.ObserveDiscovery("10.0.0.77", "10.0.0.255", probe)
+	d.ObserveTCP("10.0.0.2", "10.0.0.9", 50000, 1026)
+	if d.IconIP() != "10.0.0.2" || d.Icon().Source != SourceTCP {
+		t.Fatalf("unexpected Icon: %s", d.Icon())
+	}
+	if !d.IsIconFrame("10.0.0.2", "10.0.0.9") {
+		t.Fatal("port 1026 traffic not identified")
+	}
+}
+
+func TestTCPReplacesProbe(t *testing.T) {
+	probe, err := (&discovery.Probe{}).Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//The laptop probes before the Icon connects
+	d := New("", "")
+	d.ObserveDiscovery("10.0.0.77", "10.0.0.255", probe)
+	if d.IconIP() != "10.0.0.77" || d.Icon().Source != SourceProbe {
+		t.Fatalf("unexpected Icon: %s", d.Icon())
+	}
+	d.ObserveTCP("10.0.0.2", "10.0.0.9", 50000, 1026)
+	if d.IconIP() != "10.0.0.2" || d.FrameIP() != "10.0.0.9" || d.Icon().Source != SourceTCP {
+		t.Fatalf("unexpected roles: %s, %s", d.Icon(), d.Frame())
+	}
+
+	//A second connection does not replace the first one
+	d.ObserveTCP("10.0.0.3", "10.0.0.8", 50001, 1026)
+	if d.IconIP() != "10.0.0.2" || d.FrameIP() != "10.0.0.9" {
+		t.Fatalf("unexpected roles: %s, %s", d.Icon(), d.Frame())
+	}
+}
+
+func TestDiscoveryRoleFollowsMAC(t *testing.T) {
+	response := frameResponse(t)
+
+	d := New("", "")
+	d.ObserveMAC("10.0.0.9", frameMAC)
+	d.ObserveDiscovery("10.0.0.9", "10.0.0.2", response)
+	if d.FrameIP() != "10.0.0.9" || d.Frame().Source != SourceDiscovery {
+		t.Fatalf("unexpected Frame: %s", d.Frame())
+	}
+
+	//Same MAC, new IP not seen in the M6000 traffic
+	d.ObserveMAC("10.0.0.50", frameMAC)
+	if d.FrameIP() != "10.0.0.9" {
+		t.Fatalf("Frame moved to an IP without M6000 traffic: %s", d.Frame())
+	}
+
+	//The Frame got a new address and answers the probes from there
+	d.ObserveDiscovery("10.0.0.50", "10.0.0.2", response)
+	d.ObserveMAC("10.0.0.50", frameMAC)
+	if d.FrameIP() != "10.0.0.50" || d.Frame().Source != SourceMAC {
+		t.Fatalf("Frame did not follow its MAC address: %s", d.Frame())
+	}
+
+	//Both addresses keep answering, no flip back
+	d.ObserveDiscovery("10.0.0.9", "10.0.0.2", response)
+	d.ObserveMAC("10.0.0.9", frameMAC)
+	if d.FrameIP() != "10.0.0.50" {
+		t.Fatalf("Frame flipped back: %s", d.Frame())
+	}
+}
+
+func TestRouterMAC(t *testing.T) {
+	const routerMAC = "00:11:22:33:44:fe"
+	response := frameResponse(t)
+
+	//Routed Frame, its packets carry the gateway MAC address
+	d := New("", "")
+	d.ObserveMAC("10.1.0.9", routerMAC)
+	d.ObserveDiscovery("10.1.0.9", "10.0.0.2", response)
+	d.ObserveMAC("10.1.0.10", routerMAC)
+	d.ObserveMAC("10.1.0.11", routerMAC)
+	d.ObserveDiscovery("10.1.0.11", "10.0.0.2", response)
+	d.ObserveMAC("10.1.0.11", routerMAC)
+	if d.FrameIP() != "10.1.0.9" {
+		t.Fatalf("Frame moved through a router MAC: %s", d.Frame())
+	}
+}
+
+func TestOverride(t *testing.T) {
+	d := New("10.0.0.2", "auto")
+	d.ObserveMAC("10.0.0.2", iconMAC)
+	d.ObserveTCP("10.0.0.7", "10.0.0.9", 50000, 1026)
+	if d.IconIP() != "10.0.0.2" || d.Icon().Source != SourceOverride || d.FrameIP() != "" {
+		t.Fatalf("unexpected roles: %s, %s", d.Icon(), d.Frame())
+	}
+
+	d.ObserveTCP("10.0.0.9", "10.0.0.2", 1026, 50000)
+	if d.FrameIP() != "10.0.0.9" || d.Frame().Source != SourceTCP {
+		t.Fatalf("unexpected Frame: %s", d.Frame())
+	}
+}
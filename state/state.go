@@ -0,0 +1,174 @@
+package state
+
+import (
+	"fmt"
+	"m6kparse/sysex"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Value is the latest value seen for a parameter
+type Value struct {
+	Raw       uint16
+	Session   int
+	Timestamp time.Time
+}
+
+// Change is fired when a parameter is seen for the first time or when its value changes
+type Change struct {
+	Address   sysex.ParamAddress
+	Old       Value
+	New       Value
+	HadOld    bool // Old is not set if the parameter was not seen before
+	Timestamp time.Time
+}
+
+func (c Change) String() string {
+	if !c.HadOld {
+		return fmt.Sprintf("%s = 0x%04x", c.Address, c.New.Raw)
+	}
+	return fmt.Sprintf("%s 0x%04x -> 0x%04x", c.Address, c.Old.Raw, c.New.Raw)
+}
+
+type Subscriber func(c Change)
+
+// Mirror keeps the latest value of every parameter seen in ParamData responses
+type Mirror struct {
+	mutex       sync.Mutex
+	values      map[sysex.ParamAddress]Value
+	subscribers map[int]Subscriber
+	lastSubID   int
+}
+
+func New() *Mirror {
+	var m Mirror
+	m.values = make(map[sysex.ParamAddress]Value)
+	m.subscribers = make(map[int]Subscriber)
+	return &m
+}
+
+// Apply updates the mirror with the values of a ParamData response
+func (m *Mirror) Apply(session int, ts time.Time, data *sysex.ParamData) {
+	var changes []Change
+
+	m.mutex.Lock()
+	for i, raw := range data.Values {
+		addr := data.Address(i)
+		v := Value{Raw: raw, Session: session, Timestamp: ts}
+		old, found := m.values[addr]
+		m.values[addr] = v
+		if !found || old.Raw != raw {
+			changes = append(changes, Change{Address: addr, Old: old, New: v, HadOld: found, Timestamp: ts})
+		}
+	}
+	subscribers := m.sortedSubscribers()
+	m.mutex.Unlock()
+
+	for _, c := range changes {
+		for _, s := range subscribers {
+			s(c)
+		}
+	}
+}
+
+// Get returns the latest value of a parameter
+func (m *Mirror) Get(addr sysex.ParamAddress) (Value, bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	v, found := m.values[addr]
+	return v, found
+}
+
+// Addresses returns the address of all parameters seen, sorted
+func (m *Mirror) Addresses() []sysex.ParamAddress {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	addrs := make([]sysex.ParamAddress, 0, len(m.values))
+	for addr := range m.values {
+		addrs = append(addrs, addr)
+	}
+	sort.Slice(addrs, func(i, j int) bool { return Less(addrs[i], addrs[j]) })
+	return addrs
+}
+
+// Snapshot returns a copy of all the values seen
+func (m *Mirror) Snapshot() map[sysex.ParamAddress]Value {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	snapshot := make(map[sysex.ParamAddress]Value, len(m.values))
+	for addr, v := range m.values {
+		snapshot[addr] = v
+	}
+	return snapshot
+}
+
+// Subscribe registers a function called on each change, returns a function cancelling the subscription
+func (m *Mirror) Subscribe(s Subscriber) func() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.lastSubID++
+	id := m.lastSubID
+	m.subscribers[id] = s
+	return func() {
+		m.mutex.Lock()
+		defer m.mutex.Unlock()
+		delete(m.subscribers, id)
+	}
+}
+
+func (m *Mirror) sortedSubscribers() []Subscriber {
+	ids := make([]int, 0, len(m.subscribers))
+	for id := range m.subscribers {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	subscribers := make([]Subscriber, 0, len(ids))
+	for _, id := range ids {
+		subscribers = append(subscribers, m.subscribers[id])
+	}
+	return subscribers
+}
+
+// Less orders addresses by engine, unknown field and param
+func Less(a sysex.ParamAddress, b sysex.ParamAddress) bool {
+	if a.Engine != b.Engine {
+		return a.Engine < b.Engine
+	}
+	if a.Unknown != b.Unknown {
+		return a.Unknown < b.Unknown
+	}
+	return a.Param < b.Param
+}
+
+// Difference is a parameter whose value is not the same in two snapshots
+type Difference struct {
+	Address sysex.ParamAddress
+	A       Value
+	B       Value
+	InA     bool // A is not set if the parameter was not seen in the first snapshot
+	InB     bool // B is not set if the parameter was not seen in the second snapshot
+}
+
+// Diff compares two snapshots, the differences are sorted by address
+func Diff(a map[sysex.ParamAddress]Value, b map[sysex.ParamAddress]Value) []Difference {
+	var diffs []Difference
+
+	for addr, va := range a {
+		vb, found := b[addr]
+		if !found || va.Raw != vb.Raw {
+			diffs = append(diffs, Difference{Address: addr, A: va, B: vb, InA: true, InB: found})
+		}
+	}
+	for addr, vb := range b {
+		if _, found := a[addr]; !found {
+			diffs = append(diffs, Difference{Address: addr, B: vb, InB: true})
+		}
+	}
+	sort.Slice(diffs, func(i, j int) bool { return Less(diffs[i].Address, diffs[j].Address) })
+	return diffs
+}
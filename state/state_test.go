@@ -0,0 +1,120 @@
+package state
+
+import (
+	"m6kparse/sysex"
+	"slices"
+	"testing"
+	"time"
+)
+
+var start = time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+func paramData(engine byte, param byte, values ...uint16) *sysex.ParamData {
+	return &sysex.ParamData{Engine: engine, Param: param, Values: values}
+}
+
+func TestApply(t *testing.T) {
+	m := New()
+	m.Apply(1, start, paramData(6, 10, 0x10, 0x20, 0x30))
+	m.Apply(2, start.Add(time.Second), paramData(6, 11, 0x21, 0x30))
+
+	tests := []struct {
+		param   int
+		raw     uint16
+		session int
+	}{
+		{10, 0x10, 1},
+		{11, 0x21, 2},
+		{12, 0x30, 2},
+	}
+	for _, tt := range tests {
+		v, found := m.Get(sysex.ParamAddress{Engine: 6, Param: tt.param})
+		if !found || v.Raw != tt.raw || v.Session != tt.session {
+			t.Errorf("param %d: %+v, expected 0x%x from session %d", tt.param, v, tt.raw, tt.session)
+		}
+	}
+	if _, found := m.Get(sysex.ParamAddress{Engine: 6, Param: 13}); found {
+		t.Error("param 13 never seen")
+	}
+
+	m.Apply(1, start, paramData(1, 0, 0x01))
+	expected := []sysex.ParamAddress{{Engine: 1, Param: 0}, {Engine: 6, Param: 10}, {Engine: 6, Param: 11}, {Engine: 6, Param: 12}}
+	if addrs := m.Addresses(); !slices.Equal(addrs, expected) {
+		t.Fatalf("addresses %v, expected %v", addrs, expected)
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	m := New()
+	var first, second []Change
+	m.Subscribe(func(c Change) { first = append(first, c) })
+	cancel := m.Subscribe(func(c Change) { second = append(second, c) })
+
+	m.Apply(1, start, paramData(6, 10, 0x10, 0x20))
+	m.Apply(1, start.Add(time.Second), paramData(6, 10, 0x10, 0x21))
+	if len(first) != 3 || len(second) != 3 {
+		t.Fatalf("%d and %d changes, expected 3 (unchanged values are not reported)", len(first), len(second))
+	}
+	c := first[2]
+	if !c.HadOld || c.Old.Raw != 0x20 || c.New.Raw != 0x21 || c.Address.Param != 11 || !c.Timestamp.Equal(start.Add(time.Second)) {
+		t.Fatalf("unexpected change %s", c)
+	}
+	if first[0].HadOld {
+		t.Fatalf("first value reported with an old value: %s", first[0])
+	}
+
+	cancel()
+	cancel()
+	m.Apply(1, start.Add(2*time.Second), paramData(6, 10, 0x11))
+	if len(first) != 4 || len(second) != 3 {
+		t.Fatalf("%d and %d changes after cancelling the second subscription", len(first), len(second))
+	}
+}
+
+func TestSubscribeFromSubscriber(t *testing.T) {
+	//Subscribers are called without the lock held
+	m := New()
+	var values []uint16
+	m.Subscribe(func(c Change) {
+		if v, found := m.Get(c.Address); found {
+			values = append(values, v.Raw)
+		}
+	})
+	m.Apply(1, start, paramData(6, 10, 0x10))
+	if !slices.Equal(values, []uint16{0x10}) {
+		t.Fatalf("unexpected values %v", values)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	a := New()
+	a.Apply(1, start, paramData(6, 10, 0x10, 0x20, 0x30))
+	b := New()
+	b.Apply(2, start, paramData(6, 11, 0x21, 0x30, 0x40))
+
+	diffs := Diff(a.Snapshot(), b.Snapshot())
+	expected := []struct {
+		param int
+		inA   bool
+		inB   bool
+		rawA  uint16
+		rawB  uint16
+	}{
+		{10, true, false, 0x10, 0},
+		{11, true, true, 0x20, 0x21},
+		{13, false, true, 0, 0x40},
+	}
+	if len(diffs) != len(expected) {
+		t.Fatalf("%d differences, expected %d: %v", len(diffs), len(expected), diffs)
+	}
+	for i, e := range expected {
+		d := diffs[i]
+		if d.Address.Param != e.param || d.InA != e.inA || d.InB != e.inB || d.A.Raw != e.rawA || d.B.Raw != e.rawB {
+			t.Errorf("difference %d: %+v", i, d)
+		}
+	}
+
+	if diffs := Diff(a.Snapshot(), a.Snapshot()); len(diffs) != 0 {
+		t.Fatalf("differences with itself: %v", diffs)
+	}
+}
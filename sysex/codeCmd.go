@@ -0,0 +1,121 @@
+package sysex
+
+import (
+	"bytes"
+	"fmt"
+	"m6kparse/codec"
+)
+
+/*
+Licence code submitted by the Icon
+
+	Byte 0 : Unknown
+	Byte 1 : Unknown (0x7F)
+	Byte 2.. : Code, strings are encoded into 2 bytes per character (1 nibble per byte)
+	... : Padding (null terminator)
+*/
+type CodeCmd struct {
+	Header
+	Unknown byte
+	Marker  byte
+	Code    string
+	Padding []byte
+}
+
+func (m *CodeCmd) Type() byte {
+	return SYXTYPE_CODECMD
+}
+
+func (m *CodeCmd) String() string {
+	return "Licence submit: " + m.Code
+}
+
+func (m *CodeCmd) marshalData() []byte {
+	data := []byte{m.Unknown, m.Marker}
+	data = append(data, codec.EncodeNibbles([]byte(m.Code))...)
+	return append(data, m.Padding...)
+}
+
+func (m *CodeCmd) unmarshalData(data []byte) error {
+	if len(data) < 2 {
+		return ErrInvalidLength
+	}
+	m.Unknown = data[0]
+	m.Marker = data[1]
+
+	var code []byte
+	offs := 2
+	for ; offs+1 < len(data); offs += 2 {
+		c, err := codec.DecodeNibble(data[offs], data[offs+1])
+		if err != nil || c == 0 {
+			break
+		}
+		code = append(code, c)
+	}
+	m.Code = string(code)
+	m.Padding = bytes.Clone(data[offs:])
+	return nil
+}
+
+/*
+Licence code validation result sent by the Frame
+
+	Byte 0-1 : Unknown
+	Byte 2 : Result
+*/
+type CodeCmdResponse struct {
+	Header
+	Unknown [2]byte
+	Result  byte
+}
+
+func (m *CodeCmdResponse) Type() byte {
+	return SYXTYPE_CODECMD_RESPONSE
+}
+
+func (m *CodeCmdResponse) String() string {
+	return "Licence response: " + m.ResultString()
+}
+
+/*
+Result strings found in the Frame firmware:
+
+	1 = 00713658 + 1*4 = 0071365C => 70FB30 An unspecified error occured
+	2 = 00713658 + 2*4 = 00713660 => 71363C Code length is invalid
+	3 = 00713658 + 3*4 = 00713664 => 713620 Code identifier is invalid
+	4 = 00713658 + 4*4 = 00713668 => 713608 Code level is invalid
+	5 = 00713658 + 5*4 = 0071366C => 7135EC Code checksum is invalid
+	6 = 00713658 + 6*4 = 00713670 => 7135D0 Device EEPROM is invalid
+*/
+func (m *CodeCmdResponse) ResultString() string {
+	switch m.Result {
+	case 0:
+		return "Success" //when sending already validated licence
+	case 1: //Any invalid error code defaults to this
+		return "An unspecified error occured"
+	case 2:
+		return "Code length is invalid" // if not 20+1+8
+	case 3:
+		return "Code identifier is invalid" // - replaced
+	case 4:
+		return "Code level is invalid"
+	case 5:
+		return "Code checksum is invalid" //when sending with last digit changed
+	case 6:
+		return "Device EEPROM is invalid"
+	}
+	return fmt.Sprintf("Unknown 0x%02x", m.Result)
+}
+
+func (m *CodeCmdResponse) marshalData() []byte {
+	return []byte{m.Unknown[0], m.Unknown[1], m.Result}
+}
+
+func (m *CodeCmdResponse) unmarshalData(data []byte) error {
+	if len(data) != 3 {
+		return ErrInvalidLength
+	}
+	m.Unknown = [2]byte{data[0], data[1]}
+	m.Result = data[2]
+	return nil
+}
@@ -0,0 +1,76 @@
+package sysex
+
+import (
+	"fmt"
+	"m6kparse/codec"
+)
+
+/*
+Response to a ParamRequest, 4 bytes followed by one 14 bits value per requested parameter
+
+	Byte 0 : Engine
+	Byte 1 : Base parameter (matching the request)
+	Byte 2-3 : Unknown (14 bits, matching the request)
+	Byte 4-5 : Value of the base parameter
+	Byte 6-7 : Value of the base parameter + 1
+	...
+
+Example:
+
+00000000  06 00 00 24 00 05 40 00                           |...$..@.|
+*/
+type ParamData struct {
+	Header
+	Engine  byte
+	Param   byte
+	Unknown uint16
+	Values  []uint16
+}
+
+// ParamAddress identifies a single parameter
+type ParamAddress struct {
+	Engine  byte
+	Param   int
+	Unknown uint16
+}
+
+func (a ParamAddress) String() string {
+	return fmt.Sprintf("Eng %d - Param %d [unk: x%04x]", a.Engine, a.Param, a.Unknown)
+}
+
+// Address returns the address of the i-th value
+func (m *ParamData) Address(i int) ParamAddress {
+	return ParamAddress{Engine: m.Engine, Param: int(m.Param) + i, Unknown: m.Unknown}
+}
+
+func (m *ParamData) Type() byte {
+	return SYXTYPE_PARAMDATA
+}
+
+func (m *ParamData) String() string {
+	return fmt.Sprintf("Param data (Eng %d - Param %d - %d values)", m.Engine, m.Param, len(m.Values))
+}
+
+func (m *ParamData) marshalData() []byte {
+	unkA, unkB := codec.Encode14(m.Unknown)
+	data := []byte{m.Engine, m.Param, unkA, unkB}
+	for _, v := range m.Values {
+		a, b := codec.Encode14(v)
+		data = append(data, a, b)
+	}
+	return data
+}
+
+func (m *ParamData) unmarshalData(data []byte) error {
+	if len(data) < 4 || len(data)%2 != 0 {
+		return ErrInvalidLength
+	}
+	m.Engine = data[0]
+	m.Param = data[1]
+	m.Unknown = codec.Decode14(data[2], data[3])
+	m.Values = nil
+	for offs := 4; offs < len(data); offs += 2 {
+		m.Values = append(m.Values, codec.Decode14(data[offs], data[offs+1]))
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package sysex
+
+import (
+	"fmt"
+	"m6kparse/codec"
+)
+
+/*
+This is a 6 bytes message
+
+	Byte 0 : Engine
+	Byte 1 : Base parameter
+	Byte 2-3 : Unknown (14 bits)
+	Byte 4-5 : Number of parameters requested (14 bits)
+
+Example:
+
+00000000  06 00 00 24 00 02                                 |...$..|
+00000000  06 0b 00 16 00 04                                 |......|
+00000000  06 78 00 00 00 2a                                 |.x...*|
+00000000  06 7f 00 00 00 26                                 |.....&|
+*/
+type ParamRequest struct {
+	Header
+	Engine  byte
+	Param   byte
+	Unknown uint16
+	Count   uint16
+}
+
+func (m *ParamRequest) Type() byte {
+	return SYXTYPE_PARAMREQUEST
+}
+
+func (m *ParamRequest) String() string {
+	return fmt.Sprintf("Param request (Eng %d - Param %d - Count %d)", m.Engine, m.Param, m.Count)
+}
+
+func (m *ParamRequest) marshalData() []byte {
+	unkA, unkB := codec.Encode14(m.Unknown)
+	countA, countB := codec.Encode14(m.Count)
+	return []byte{m.Engine, m.Param, unkA, unkB, countA, countB}
+}
+
+func (m *ParamRequest) unmarshalData(data []byte) error {
+	if len(data) != 6 {
+		return ErrInvalidLength
+	}
+	m.Engine = data[0]
+	m.Param = data[1]
+	m.Unknown = codec.Decode14(data[2], data[3])
+	m.Count = codec.Decode14(data[4], data[5])
+	return nil
+}
@@ -0,0 +1,129 @@
+package sysex
+
+import (
+	"bytes"
+	"fmt"
+)
+
+/*
+Preset messages start with the preset number on two bytes, least significant first.
+
+PresetRequest is a 5 bytes message:
+
+ Byte 0-1 : Preset number
+ Byte 2-4 : Unknown
+
+PresetData:
+
+ Byte 0-1 : Preset number
+ Byte 2.. : Preset content
+
+PresetRecall layout is assumed to match PresetRequest (as on the M-One and D-Two).
+*/
+
+type PresetRequest struct {
+	Header
+	Number uint16
+	Extra  []byte
+}
+
+func (m *PresetRequest) Type() byte {
+	return SYXTYPE_PRESETREQUEST
+}
+
+func (m *PresetRequest) String() string {
+	return fmt.Sprintf("Preset request %d", m.Number)
+}
+
+func (m *PresetRequest) marshalData() []byte {
+	a, b := encodePresetNumber(m.Number)
+	return append([]byte{a, b}, m.Extra...)
+}
+
+func (m *PresetRequest) unmarshalData(data []byte) error {
+	if len(data) < 2 {
+		return ErrInvalidLength
+	}
+	m.Number = decodePresetNumber(data[0], data[1])
+	m.Extra = bytes.Clone(data[2:])
+	return nil
+}
+
+type PresetData struct {
+	Header
+	Number uint16
+	Data   []byte
+}
+
+func (m *PresetData) Type() byte {
+	return SYXTYPE_PRESETDATA
+}
+
+func (m *PresetData) String() string {
+	return fmt.Sprintf("Preset data %d", m.Number)
+}
+
+func (m *PresetData) marshalData() []byte {
+	a, b := encodePresetNumber(m.Number)
+	return append([]byte{a, b}, m.Data...)
+}
+
+func (m *PresetData) unmarshalData(data []byte) error {
+	if len(data) < 2 {
+		return ErrInvalidLength
+	}
+	m.Number = decodePresetNumber(data[0], data[1])
+	m.Data = bytes.Clone(data[2:])
+	return nil
+}
+
+type PresetRecall struct {
+	Header
+	Number uint16
+	Extra  []byte
+}
+
+func (m *PresetRecall) Type() byte {
+	return SYXTYPE_PRESETRECALL
+}
+
+func (m *PresetRecall) String() string {
+	return fmt.Sprintf("Preset recall %d", m.Number)
+}
+
+func (m *PresetRecall) marshalData() []byte {
+	a, b := encodePresetNumber(m.Number)
+	return append([]byte{a, b}, m.Extra...)
+}
+
+func (m *PresetRecall) unmarshalData(data []byte) error {
+	if len(data) < 2 {
+		return ErrInvalidLength
+	}
+	m.Number = decodePresetNumber(data[0], data[1])
+	m.Extra = bytes.Clone(data[2:])
+	return nil
+}
+
+// BankRequest layout has not been identified yet
+type BankRequest struct {
+	Header
+	Data []byte
+}
+
+func (m *BankRequest) Type() byte {
+	return SYXTYPE_BANKREQUEST
+}
+
+func (m *BankRequest) String() string {
+	return fmt.Sprintf("Bank request (%d bytes)", len(m.Data))
+}
+
+func (m *BankRequest) marshalData() []byte {
+	return bytes.Clone(m.Data)
+}
+
+func (m *BankRequest) unmarshalData(data []byte) error {
+	m.Data = bytes.Clone(data)
+	return nil
+}
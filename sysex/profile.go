@@ -0,0 +1,97 @@
+package sysex
+
+import (
+	"bytes"
+	"errors"
+)
+
+/*
+TC Electronic devices share the same SysEx header:
+
+ Byte 0 : F0
+ Byte 1-2-3 : Midi identifier for TC Electronic : 00 20 1f
+ Byte 4 : Device Sysex ID
+ Byte 5 : Model id (M-One 0x44, D-Two 0x45, M6000 0x46)
+ Byte 6 : Message type
+
+The M5000 uses a different header:
+
+ Byte 0 : F0
+ Byte 1 : TC identifier 0x33
+ Byte 2 : Device Sysex ID
+ Byte 3 : Card identifier
+ Byte 4 : Packet type (0x00 to 0x07)
+
+The M3000 shares the TC header but its model ID is not known here (see its SysEx
+specification linked in the README), it can be added with RegisterProfile.
+*/
+
+var (
+	ErrUnknownModel = errors.New("unknown TC Electronic model")
+)
+
+// Profile describes the SysEx header used by a TC Electronic device
+type Profile struct {
+	Name         string
+	Manufacturer []byte
+	ModelID      byte // Model byte following the device ID
+	AnyModel     bool // Byte following the device ID is not a fixed model ID (M5000 card identifier)
+	Typed        bool // Message data layouts are known for this device
+}
+
+var tcManufacturerID = []byte{0x00, 0x20, 0x1F}
+
+var (
+	ProfileM6000 = &Profile{Name: "M6000", Manufacturer: tcManufacturerID, ModelID: ModelM6000, Typed: true}
+	ProfileMOne  = &Profile{Name: "M-One", Manufacturer: tcManufacturerID, ModelID: 0x44}
+	ProfileDTwo  = &Profile{Name: "D-Two", Manufacturer: tcManufacturerID, ModelID: 0x45}
+	ProfileM5000 = &Profile{Name: "M5000", Manufacturer: []byte{0x33}, AnyModel: true}
+)
+
+var profiles = []*Profile{ProfileM6000, ProfileMOne, ProfileDTwo, ProfileM5000}
+
+// RegisterProfile adds a device to the list of identified devices
+func RegisterProfile(p *Profile) {
+	profiles = append(profiles, p)
+}
+
+// Profiles returns the list of identified devices
+func Profiles() []*Profile {
+	return profiles
+}
+
+// HeaderSize returns the number of bytes before the message data (F0 and message type included)
+func (p *Profile) HeaderSize() int {
+	return 1 + len(p.Manufacturer) + 3
+}
+
+// match tells if a SysEx message targets this device
+func (p *Profile) match(data []byte) bool {
+	if len(data) < p.HeaderSize()+1 {
+		return false
+	}
+	if !bytes.Equal(data[1:1+len(p.Manufacturer)], p.Manufacturer) {
+		return false
+	}
+	return p.AnyModel || data[1+len(p.Manufacturer)+1] == p.ModelID
+}
+
+// identify finds which device a SysEx message targets
+func identify(data []byte) (*Profile, error) {
+	for _, p := range profiles {
+		if p.match(data) {
+			return p, nil
+		}
+	}
+
+	//Known manufacturer, unknown model
+	for _, p := range profiles {
+		if len(data) > len(p.Manufacturer) && bytes.Equal(data[1:1+len(p.Manufacturer)], p.Manufacturer) {
+			if len(data) < p.HeaderSize()+1 {
+				return nil, ErrTooShort
+			}
+			return nil, ErrUnknownModel
+		}
+	}
+	return nil, ErrNotTC
+}
@@ -0,0 +1,34 @@
+package sysex
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// RawUnknown holds messages whose layout is not known
+type RawUnknown struct {
+	Header
+	MsgType byte
+	Data    []byte
+}
+
+func (m *RawUnknown) Type() byte {
+	return m.MsgType
+}
+
+func (m *RawUnknown) String() string {
+	//Message types are only known for the M6000
+	if m.Profile != nil && !m.Profile.Typed {
+		return fmt.Sprintf("%s message 0x%02x (%d bytes)", m.Profile.Name, m.MsgType, len(m.Data))
+	}
+	return fmt.Sprintf("%s (%d bytes)", TypeName(m.MsgType), len(m.Data))
+}
+
+func (m *RawUnknown) marshalData() []byte {
+	return bytes.Clone(m.Data)
+}
+
+func (m *RawUnknown) unmarshalData(data []byte) error {
+	m.Data = bytes.Clone(data)
+	return nil
+}
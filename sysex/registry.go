@@ -0,0 +1,107 @@
+package sysex
+
+import (
+	"errors"
+	"fmt"
+	"m6kparse/common"
+	"slices"
+)
+
+const (
+	SYXTYPE_UNKNOWN_23 = 0x23 // variable message len
+	SYXTYPE_UNKNOWN_28 = 0x28 // 5 bytes messages
+	SYXTYPE_UNKNOWN_29 = 0x29 // 3 or 72 bytes messages
+	SYXTYPE_UNKNOWN_2F = 0x2F // variable message len
+	SYXTYPE_UNKNOWN_43 = 0x43 // 5 bytes messages
+	SYXTYPE_UNKNOWN_49 = 0x49 // 3 bytes messages
+	SYXTYPE_UNKNOWN_4A = 0x4A // 3 bytes messages
+	SYXTYPE_UNKNOWN_4F = 0x4F // 3 bytes messages
+
+	SYXTYPE_PRESETCMD_4C = 0x4C // possible preset command from icon
+	SYXTYPE_MEDIACMD_4D  = 0x4D // possible media command from icon
+)
+
+var (
+	ErrUnexpectedDirection = errors.New("unexpected message direction")
+	ErrUnknownType         = errors.New("unknown message type")
+)
+
+// TypeInfo describes what is known about a message type
+type TypeInfo struct {
+	Type      byte
+	Name      string
+	Direction common.Direction
+	Lengths   []int // Known message data lengths, nil if variable
+	Pair      byte  // Matching request or response type, if HasPair is set
+	HasPair   bool
+	Request   bool // Set if Pair is the response to this message
+}
+
+// 0x25 difference between request and response
+var types = []TypeInfo{
+	{Type: SYXTYPE_PRESETREQUEST, Name: "PresetRequest", Direction: common.IconToFrame, Lengths: []int{5}, Pair: SYXTYPE_PRESETDATA, HasPair: true, Request: true},
+	{Type: SYXTYPE_PRESETDATA, Name: "PresetData", Direction: common.FrameToIcon, Pair: SYXTYPE_PRESETREQUEST, HasPair: true},
+	{Type: SYXTYPE_RHYTHMREQUEST, Name: "RhythmRequest", Direction: common.IconToFrame, Lengths: []int{9}, Pair: SYXTYPE_RHYTHMDATA, HasPair: true, Request: true},
+	{Type: SYXTYPE_RHYTHMDATA, Name: "RhythmData", Direction: common.FrameToIcon, Lengths: []int{72, 124}, Pair: SYXTYPE_RHYTHMREQUEST, HasPair: true},
+	{Type: SYXTYPE_PARAMREQUEST, Name: "ParamRequest", Direction: common.IconToFrame, Lengths: []int{6}, Pair: SYXTYPE_PARAMDATA, HasPair: true, Request: true},
+	{Type: SYXTYPE_PARAMDATA, Name: "ParamData", Direction: common.FrameToIcon, Pair: SYXTYPE_PARAMREQUEST, HasPair: true},
+	{Type: SYXTYPE_CODECMD, Name: "Licence submit", Direction: common.IconToFrame, Pair: SYXTYPE_CODECMD_RESPONSE, HasPair: true, Request: true},
+	{Type: SYXTYPE_CODECMD_RESPONSE, Name: "Licence submit response", Direction: common.FrameToIcon, Lengths: []int{3}, Pair: SYXTYPE_CODECMD, HasPair: true},
+
+	{Type: SYXTYPE_BANKREQUEST, Name: "BankRequest", Direction: common.IconToFrame},
+	{Type: SYXTYPE_PRESETRECALL, Name: "PresetRecall", Direction: common.IconToFrame},
+
+	{Type: SYXTYPE_UNKNOWN_23, Name: "Frame to icon unknown 23", Direction: common.FrameToIcon},
+	{Type: SYXTYPE_UNKNOWN_28, Name: "Frame to icon unknown 28", Direction: common.FrameToIcon, Lengths: []int{5}},
+	{Type: SYXTYPE_UNKNOWN_29, Name: "Frame to icon unknown 29", Direction: common.FrameToIcon, Lengths: []int{3, 72}},
+	{Type: SYXTYPE_UNKNOWN_2F, Name: "Frame to icon unknown 2F", Direction: common.FrameToIcon},
+	{Type: SYXTYPE_UNKNOWN_43, Name: "Icon to frame unknown 43", Direction: common.IconToFrame, Lengths: []int{5}},
+	{Type: SYXTYPE_UNKNOWN_49, Name: "Icon to frame unknown 49", Direction: common.IconToFrame, Lengths: []int{3}},
+	{Type: SYXTYPE_UNKNOWN_4A, Name: "Icon to frame unknown 4A", Direction: common.IconToFrame, Lengths: []int{3}},
+	{Type: SYXTYPE_UNKNOWN_4F, Name: "Icon to frame unknown 4F", Direction: common.IconToFrame, Lengths: []int{3}},
+	{Type: SYXTYPE_PRESETCMD_4C, Name: "Icon to frame possible preset command", Direction: common.IconToFrame},
+	{Type: SYXTYPE_MEDIACMD_4D, Name: "Icon to frame possible media command", Direction: common.IconToFrame},
+}
+
+var registry = map[byte]TypeInfo{}
+
+func init() {
+	for _, info := range types {
+		registry[info.Type] = info
+	}
+}
+
+// Lookup returns what is known about a message type
+func Lookup(msgType byte) (TypeInfo, bool) {
+	info, found := registry[msgType]
+	return info, found
+}
+
+// TypeName returns the name of a message type
+func TypeName(msgType byte) string {
+	info, found := registry[msgType]
+	if !found {
+		return fmt.Sprintf("Unk %02x", msgType)
+	}
+	return info.Name
+}
+
+// ValidLength tells if a message data length matches the known lengths
+func (info TypeInfo) ValidLength(length int) bool {
+	return info.Lengths == nil || slices.Contains(info.Lengths, length)
+}
+
+// Check validates a message type, its direction and its data length against the registry
+func Check(msgType byte, dir common.Direction, length int) error {
+	info, found := registry[msgType]
+	if !found {
+		return fmt.Errorf("0x%02x: %w", msgType, ErrUnknownType)
+	}
+	if info.Direction != dir {
+		return fmt.Errorf("%s on %s: %w", info.Name, dir, ErrUnexpectedDirection)
+	}
+	if !info.ValidLength(length) {
+		return fmt.Errorf("%s with %d bytes: %w", info.Name, length, ErrInvalidLength)
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package sysex
+
+import (
+	"bytes"
+	"fmt"
+)
+
+/*
+Rhythm messages layout is assumed to start with the rhythm number on two bytes,
+least significant first, like the preset messages.
+
+RhythmRequest is a 9 bytes message, RhythmData a 72 or 124 bytes message.
+*/
+
+type RhythmRequest struct {
+	Header
+	Number uint16
+	Extra  []byte
+}
+
+func (m *RhythmRequest) Type() byte {
+	return SYXTYPE_RHYTHMREQUEST
+}
+
+func (m *RhythmRequest) String() string {
+	return fmt.Sprintf("Rhythm request %d", m.Number)
+}
+
+func (m *RhythmRequest) marshalData() []byte {
+	a, b := encodePresetNumber(m.Number)
+	return append([]byte{a, b}, m.Extra...)
+}
+
+func (m *RhythmRequest) unmarshalData(data []byte) error {
+	if len(data) < 2 {
+		return ErrInvalidLength
+	}
+	m.Number = decodePresetNumber(data[0], data[1])
+	m.Extra = bytes.Clone(data[2:])
+	return nil
+}
+
+type RhythmData struct {
+	Header
+	Number uint16
+	Data   []byte
+}
+
+func (m *RhythmData) Type() byte {
+	return SYXTYPE_RHYTHMDATA
+}
+
+func (m *RhythmData) String() string {
+	return fmt.Sprintf("Rhythm data %d", m.Number)
+}
+
+func (m *RhythmData) marshalData() []byte {
+	a, b := encodePresetNumber(m.Number)
+	return append([]byte{a, b}, m.Data...)
+}
+
+func (m *RhythmData) unmarshalData(data []byte) error {
+	if len(data) < 2 {
+		return ErrInvalidLength
+	}
+	m.Number = decodePresetNumber(data[0], data[1])
+	m.Data = bytes.Clone(data[2:])
+	return nil
+}
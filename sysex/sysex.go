@@ -0,0 +1,176 @@
+package sysex
+
+import (
+	"errors"
+	"fmt"
+)
+
+/*
+ Byte 0 : F0
+ Byte 1-2-3 : Midi identifier for TC Electronic : 00 20 1f
+ Byte 4 : Device Sysex ID (usually 0)
+ Byte 5 : Model id for M6000 0x46
+ Byte 6 : Message type
+ ...
+ Byte x : F7
+
+See profile.go for the other TC Electronic devices.
+*/
+
+const (
+	sysexStart = 0xF0
+	sysexEnd   = 0xF7
+
+	ModelM6000 = 0x46
+)
+
+// Message types, see registry.go for what is known about them
+const (
+	SYXTYPE_PRESETDATA    = 0x20
+	SYXTYPE_PRESETREQUEST = 0x45
+
+	SYXTYPE_RHYTHMDATA    = 0x21
+	SYXTYPE_RHYTHMREQUEST = 0x46
+
+	SYXTYPE_PARAMDATA    = 0x22
+	SYXTYPE_PARAMREQUEST = 0x47
+
+	SYXTYPE_BANKREQUEST  = 0x40
+	SYXTYPE_PRESETRECALL = 0x44
+
+	SYXTYPE_CODECMD          = 0x4E
+	SYXTYPE_CODECMD_RESPONSE = 0x2E
+)
+
+var (
+	ErrNotSysEx        = errors.New("not a SysEx message")
+	ErrTooShort        = errors.New("SysEx message too short")
+	ErrNotTC           = errors.New("foreign SysEx message (not TC Electronic)")
+	ErrInvalidLength   = errors.New("unexpected message length")
+	ErrInvalidEncoding = errors.New("unexpected data encoding")
+)
+
+// Header holds the SysEx fields found before the message type
+type Header struct {
+	Profile  *Profile // Device targeted by the message, M6000 if not set
+	DeviceID byte     // Configurable using the Icon
+	ModelID  byte     // 0x46 for M6000, card identifier for M5000
+}
+
+func (h *Header) SysExHeader() *Header {
+	return h
+}
+
+// Message is a decoded SysEx message
+type Message interface {
+	// Type returns the message type byte
+	Type() byte
+	// String returns a one line human readable description
+	String() string
+	// SysExHeader returns the fields found before the message type
+	SysExHeader() *Header
+
+	marshalData() []byte
+	unmarshalData(data []byte) error
+}
+
+// Unmarshal decodes a complete SysEx message (F0 ... F7).
+// Messages from devices other than the M6000 and unknown message types are returned as RawUnknown.
+// If the message type is known but its data cannot be decoded, a RawUnknown is returned along with the error.
+func Unmarshal(data []byte) (Message, error) {
+	if len(data) < 2 || data[0] != sysexStart || data[len(data)-1] != sysexEnd {
+		return nil, ErrNotSysEx
+	}
+	profile, err := identify(data)
+	if err != nil {
+		return nil, err
+	}
+
+	offs := 1 + len(profile.Manufacturer)
+	h := Header{Profile: profile, DeviceID: data[offs], ModelID: data[offs+1]}
+	msgType := data[offs+2]
+	payload := data[profile.HeaderSize() : len(data)-1]
+
+	msg := Message(&RawUnknown{MsgType: msgType})
+	if profile.Typed {
+		msg = newMessage(msgType)
+	}
+	*msg.SysExHeader() = h
+	err = checkDataBytes(payload)
+	if err == nil {
+		err = msg.unmarshalData(payload)
+	}
+	if err != nil {
+		raw := RawUnknown{Header: h, MsgType: msgType}
+		raw.unmarshalData(payload)
+		return &raw, fmt.Errorf("message 0x%02x: %w", msgType, err)
+	}
+	return msg, nil
+}
+
+// Marshal encodes a message into a complete SysEx message (F0 ... F7)
+func Marshal(m Message) []byte {
+	h := m.SysExHeader()
+	data := m.marshalData()
+	profile := h.Profile
+	if profile == nil {
+		profile = ProfileM6000
+	}
+	modelID := h.ModelID
+	if !profile.AnyModel {
+		modelID = profile.ModelID
+	}
+
+	msg := make([]byte, 0, profile.HeaderSize()+len(data)+1)
+	msg = append(msg, sysexStart)
+	msg = append(msg, profile.Manufacturer...)
+	msg = append(msg, h.DeviceID, modelID, m.Type())
+	msg = append(msg, data...)
+	msg = append(msg, sysexEnd)
+	return msg
+}
+
+// SysEx data bytes are 7 bits only
+func checkDataBytes(data []byte) error {
+	for _, b := range data {
+		if b&0x80 != 0 {
+			return ErrInvalidEncoding
+		}
+	}
+	return nil
+}
+
+func newMessage(msgType byte) Message {
+	switch msgType {
+	case SYXTYPE_PARAMREQUEST:
+		return new(ParamRequest)
+	case SYXTYPE_PARAMDATA:
+		return new(ParamData)
+	case SYXTYPE_PRESETREQUEST:
+		return new(PresetRequest)
+	case SYXTYPE_PRESETDATA:
+		return new(PresetData)
+	case SYXTYPE_PRESETRECALL:
+		return new(PresetRecall)
+	case SYXTYPE_BANKREQUEST:
+		return new(BankRequest)
+	case SYXTYPE_RHYTHMREQUEST:
+		return new(RhythmRequest)
+	case SYXTYPE_RHYTHMDATA:
+		return new(RhythmData)
+	case SYXTYPE_CODECMD:
+		return new(CodeCmd)
+	case SYXTYPE_CODECMD_RESPONSE:
+		return new(CodeCmdResponse)
+	}
+	return &RawUnknown{MsgType: msgType}
+}
+
+// Two bytes, least significant first
+func decodePresetNumber(a byte, b byte) uint16 {
+	return uint16(b)<<8 | uint16(a)
+}
+
+func encodePresetNumber(value uint16) (byte, byte) {
+	return byte(value), byte(value >> 8)
+}
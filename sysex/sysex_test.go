@@ -0,0 +1,186 @@
+package sysex
+
+import (
+	"bytes"
+	"errors"
+	"m6kparse/common"
+	"reflect"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	h := Header{Profile: ProfileM6000, DeviceID: 3, ModelID: ModelM6000}
+	tests := []Message{
+		&ParamRequest{Header: h, Engine: 6, Param: 0x0b, Unknown: 0x16, Count: 4},
+		&ParamData{Header: h, Engine: 6, Param: 0x7f, Unknown: 0x1234, Values: []uint16{0, 0x40, 0x3FFF}},
+		&PresetRequest{Header: h, Number: 300, Extra: []byte{0x00, 0x01, 0x02}},
+		&PresetData{Header: h, Number: 12, Data: []byte{0x00, 0x05, 0x07, 0x7F}},
+		&PresetRecall{Header: h, Number: 0x0105, Extra: []byte{0x00}},
+		&BankRequest{Header: h, Data: []byte{0x01, 0x02}},
+		&RhythmRequest{Header: h, Number: 2, Extra: []byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}},
+		&RhythmData{Header: h, Number: 2, Data: []byte{0x10, 0x20}},
+		&CodeCmd{Header: h, Unknown: 0x01, Marker: 0x7F, Code: "ABCD-1234", Padding: []byte{0x00, 0x00}},
+		&CodeCmdResponse{Header: h, Unknown: [2]byte{0x00, 0x01}, Result: 5},
+		&RawUnknown{Header: h, MsgType: SYXTYPE_UNKNOWN_28, Data: []byte{0x01, 0x02, 0x03, 0x04, 0x05}},
+	}
+	for _, m := range tests {
+		data := Marshal(m)
+		decoded, err := Unmarshal(data)
+		if err != nil {
+			t.Errorf("%s: %v", m, err)
+			continue
+		}
+		if !reflect.DeepEqual(decoded, m) {
+			t.Errorf("%s: decoded as %#v", m, decoded)
+		}
+		if !bytes.Equal(Marshal(decoded), data) {
+			t.Errorf("%s: bytes changed\n% x\n% x", m, Marshal(decoded), data)
+		}
+	}
+}
+
+func TestUnmarshalCaptured(t *testing.T) {
+	//ParamRequest and ParamData examples, see paramRequest.go and paramData.go
+	m, err := Unmarshal([]byte{0xF0, 0x00, 0x20, 0x1F, 0x00, 0x46, 0x47, 0x06, 0x7f, 0x00, 0x00, 0x00, 0x26, 0xF7})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, ok := m.(*ParamRequest)
+	if !ok || req.Engine != 6 || req.Param != 127 || req.Count != 0x26 {
+		t.Fatalf("unexpected message %#v", m)
+	}
+
+	m, err = Unmarshal([]byte{0xF0, 0x00, 0x20, 0x1F, 0x00, 0x46, 0x22, 0x06, 0x00, 0x00, 0x24, 0x00, 0x05, 0x40, 0x00, 0xF7})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, ok := m.(*ParamData)
+	if !ok || data.Engine != 6 || data.Unknown != 0x24 || !reflect.DeepEqual(data.Values, []uint16{0x05, 0x40 << 7}) {
+		t.Fatalf("unexpected message %#v", m)
+	}
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		err  error
+		raw  bool // A RawUnknown is returned along with the error
+	}{
+		{"no F7", []byte{0xF0, 0x00, 0x20, 0x1F, 0x00, 0x46, 0x47}, ErrNotSysEx, false},
+		{"foreign", []byte{0xF0, 0x41, 0x10, 0x42, 0x12, 0x40, 0x00, 0x7F, 0x00, 0x41, 0xF7}, ErrNotTC, false},
+		{"unknown TC model", []byte{0xF0, 0x00, 0x20, 0x1F, 0x00, 0x43, 0x20, 0xF7}, ErrUnknownModel, false},
+		{"short TC header", []byte{0xF0, 0x00, 0x20, 0x1F, 0x00, 0xF7}, ErrTooShort, false},
+		{"ParamRequest length", []byte{0xF0, 0x00, 0x20, 0x1F, 0x00, 0x46, 0x47, 0x06, 0x7f, 0xF7}, ErrInvalidLength, true},
+		{"8 bits data", []byte{0xF0, 0x00, 0x20, 0x1F, 0x00, 0x46, 0x20, 0x01, 0x00, 0x80, 0xF7}, ErrInvalidEncoding, true},
+	}
+	for _, tt := range tests {
+		m, err := Unmarshal(tt.data)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%s: error %v, expected %v", tt.name, err, tt.err)
+		}
+		if _, raw := m.(*RawUnknown); raw != tt.raw {
+			t.Errorf("%s: returned %#v", tt.name, m)
+		}
+	}
+}
+
+func TestProfiles(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    []byte
+		profile *Profile
+		modelID byte
+		msgType byte
+	}{
+		{"M6000", []byte{0xF0, 0x00, 0x20, 0x1F, 0x00, 0x46, 0x45, 0x0C, 0x00, 0x00, 0x00, 0x00, 0xF7}, ProfileM6000, 0x46, SYXTYPE_PRESETREQUEST},
+		{"M-One", []byte{0xF0, 0x00, 0x20, 0x1F, 0x00, 0x44, 0x45, 0x01, 0x00, 0xF7}, ProfileMOne, 0x44, 0x45},
+		{"D-Two", []byte{0xF0, 0x00, 0x20, 0x1F, 0x02, 0x45, 0x20, 0x01, 0x00, 0xF7}, ProfileDTwo, 0x45, 0x20},
+		//F0 33 <device ID> <card identifier> <packet type>
+		{"M5000", []byte{0xF0, 0x33, 0x01, 0x05, 0x02, 0x10, 0x20, 0xF7}, ProfileM5000, 0x05, 0x02},
+	}
+	for _, tt := range tests {
+		m, err := Unmarshal(tt.data)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		h := m.SysExHeader()
+		if h.Profile != tt.profile || h.ModelID != tt.modelID || m.Type() != tt.msgType {
+			t.Errorf("%s: %s, model 0x%02x, type 0x%02x", tt.name, h.Profile.Name, h.ModelID, m.Type())
+		}
+		//Message layouts are only known for the M6000
+		if _, raw := m.(*RawUnknown); raw == tt.profile.Typed {
+			t.Errorf("%s: decoded as %#v", tt.name, m)
+		}
+		if !bytes.Equal(Marshal(m), tt.data) {
+			t.Errorf("%s: bytes changed\n% x\n% x", tt.name, Marshal(m), tt.data)
+		}
+	}
+}
+
+func TestRegisterProfile(t *testing.T) {
+	saved := profiles
+	t.Cleanup(func() { profiles = saved })
+
+	data := []byte{0xF0, 0x00, 0x20, 0x1F, 0x00, 0x30, 0x20, 0x01, 0xF7}
+	if _, err := Unmarshal(data); !errors.Is(err, ErrUnknownModel) {
+		t.Fatalf("error %v, expected ErrUnknownModel", err)
+	}
+	custom := &Profile{Name: "Custom", Manufacturer: tcManufacturerID, ModelID: 0x30}
+	RegisterProfile(custom)
+	m, err := Unmarshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.SysExHeader().Profile != custom {
+		t.Fatalf("unexpected profile %s", m.SysExHeader().Profile.Name)
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	tests := []struct {
+		msgType byte
+		dir     common.Direction
+		length  int
+		err     error
+	}{
+		{SYXTYPE_PRESETREQUEST, common.IconToFrame, 5, nil},
+		{SYXTYPE_PRESETREQUEST, common.IconToFrame, 4, ErrInvalidLength},
+		{SYXTYPE_PRESETREQUEST, common.FrameToIcon, 5, ErrUnexpectedDirection},
+		{SYXTYPE_PRESETDATA, common.FrameToIcon, 1000, nil},
+		{SYXTYPE_PARAMREQUEST, common.IconToFrame, 6, nil},
+		{SYXTYPE_RHYTHMREQUEST, common.IconToFrame, 9, nil},
+		{SYXTYPE_RHYTHMDATA, common.FrameToIcon, 72, nil},
+		{SYXTYPE_RHYTHMDATA, common.FrameToIcon, 124, nil},
+		{SYXTYPE_RHYTHMDATA, common.FrameToIcon, 73, ErrInvalidLength},
+		{SYXTYPE_CODECMD_RESPONSE, common.FrameToIcon, 3, nil},
+		{SYXTYPE_UNKNOWN_29, common.FrameToIcon, 72, nil},
+		{SYXTYPE_UNKNOWN_4F, common.FrameToIcon, 3, ErrUnexpectedDirection},
+		{0x7E, common.IconToFrame, 3, ErrUnknownType},
+	}
+	for _, tt := range tests {
+		if err := Check(tt.msgType, tt.dir, tt.length); !errors.Is(err, tt.err) {
+			t.Errorf("0x%02x on %s, %d bytes: error %v, expected %v", tt.msgType, tt.dir, tt.length, err, tt.err)
+		}
+	}
+}
+
+func TestRegistryPairs(t *testing.T) {
+	for _, info := range types {
+		if !info.HasPair {
+			continue
+		}
+		pair, found := Lookup(info.Pair)
+		if !found || !pair.HasPair || pair.Pair != info.Type {
+			t.Errorf("%s: pair 0x%02x does not point back", info.Name, info.Pair)
+			continue
+		}
+		if pair.Request == info.Request || pair.Direction == info.Direction {
+			t.Errorf("%s and %s are not a request and its response", info.Name, pair.Name)
+		}
+		if info.Request && info.Direction != common.IconToFrame {
+			t.Errorf("%s: request not sent by the Icon", info.Name)
+		}
+	}
+}
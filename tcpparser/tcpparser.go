@@ -1,126 +1,312 @@
 package tcpparser
 
 import (
-	"encoding/binary"
 	"encoding/hex"
 	"log"
+	"m6kparse/catalog"
 	"m6kparse/common"
+	"m6kparse/correlator"
+	"m6kparse/decoder"
 	"m6kparse/midi"
+	"m6kparse/roles"
+	"m6kparse/sysex"
+	"time"
 
 	"github.com/google/gopacket"
 	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/tcpassembly"
 )
 
+const (
+	m6000Port = 1026
+
+	//Out of order pages kept before considering the missing segment is lost
+	maxBufferedPages = 16
+	//Time waited for a missing segment before considering it is lost
+	segmentTimeout = 2 * time.Second
+)
+
+type EventType int
+
+const (
+	EventMessage      EventType = iota
+	EventGap          EventType = iota
+	EventSessionStart EventType = iota
+	EventSessionEnd   EventType = iota
+)
+
+// Event is produced for each MIDI message found in the stream, for each gap in the stream
+// and when a TCP session starts or ends
+type Event struct {
+	Type      EventType
+	Session   int
+	Direction common.Direction
+	Timestamp time.Time
+	Message   decoder.Message      // EventMessage only
+	SysEx     sysex.Message        // EventMessage only, nil if the message is not a TC SysEx
+	Exchange  *correlator.Exchange // EventMessage only, set on responses matching a request
+	Lost      int                  // EventGap only: lost bytes, -1 if unknown
+}
+
+type Handler func(ev Event)
+
 type TCPParser struct {
-	logs                 *log.Logger
-	iconIP               string
-	frameIP              string
-	midiParser           *midi.MIDI
-	iconToFrameTruncated []byte
-	frameToIconTruncated []byte
+	logs          *log.Logger
+	roles         *roles.Detector
+	midiParser    *midi.MIDI
+	assembler     *tcpassembly.Assembler
+	lastFlush     time.Time
+	lastPacket    time.Time // Timestamp of the packet being assembled
+	handlers      []Handler
+	sessions      map[sessionKey]*session
+	lastSessionID int
+	correlator    *correlator.Correlator
+}
+
+// sessionKey identifies a TCP connection, Icon side first
+type sessionKey struct {
+	net       gopacket.Flow
+	transport gopacket.Flow
 }
 
-func New(iconIP string, frameIP string, logs *log.Logger) *TCPParser {
+// session holds the state of one TCP connection between the Icon and the Frame
+type session struct {
+	id       int
+	key      sessionKey
+	synSeq   uint32
+	decoder  *decoder.Decoder
+	closed   [2]bool
+	ended    bool
+	lastSeen time.Time
+}
+
+// tcpStream receives the reassembled data of one direction
+type tcpStream struct {
+	parser  *TCPParser
+	session *session
+	dir     common.Direction
+}
+
+func New(detector *roles.Detector, logs *log.Logger) *TCPParser {
 	var p TCPParser
 
-	p.iconIP = iconIP
-	p.frameIP = frameIP
+	p.roles = detector
 	p.logs = logs
 	p.midiParser = midi.New(logs)
+	p.sessions = make(map[sessionKey]*session)
+	p.correlator = correlator.New()
+	p.assembler = tcpassembly.NewAssembler(tcpassembly.NewStreamPool(&p))
+	p.assembler.MaxBufferedPagesPerConnection = maxBufferedPages
 	return &p
 }
 
+// SetCatalog sets the parameters catalog used to print ParamData values
+func (p *TCPParser) SetCatalog(c *catalog.Catalog) {
+	p.midiParser.SetCatalog(c)
+}
+
+// AddHandler registers a function called for each event found in the TCP stream
+func (p *TCPParser) AddHandler(h Handler) {
+	p.handlers = append(p.handlers, h)
+}
+
 func (p *TCPParser) Parse(packet gopacket.Packet, ip *layers.IPv4, tcp *layers.TCP) {
 
-	if len(tcp.Payload) == 0 {
+	if tcp.SrcPort != m6000Port && tcp.DstPort != m6000Port {
 		return
 	}
-	p.logs.Println("************************************************************")
-	p.logs.Printf("[TCP Packet] RAW Payload %d bytes (0x%d)\n", len(tcp.Payload), len(tcp.Payload))
-	p.logs.Print("\n" + hex.Dump(tcp.Payload))
-
-	if (ip.SrcIP.String() == p.frameIP) && (ip.DstIP.String() == p.iconIP) {
-		p.logs.Println("-> Frame to icon (tcp)")
-		p.ParseBlocks(tcp.Payload, common.FrameToIcon)
-	} else if (ip.SrcIP.String() == p.iconIP) && (ip.DstIP.String() == p.frameIP) {
-		p.logs.Println("-> Icon to frame (tcp)")
-		p.ParseBlocks(tcp.Payload, common.IconToFrame)
-	}
-}
-
-func (p *TCPParser) ParseBlocks(payload []byte, d common.Direction) {
-	offs := 0
-	msg := 1
-
-	//If data was truncated on previous packet, prepend saved
-	if (d == common.IconToFrame) && (len(p.iconToFrameTruncated) != 0) {
-		p.logs.Printf("-> Reusing %d bytes from previously truncated packet\n", len(p.iconToFrameTruncated))
-		merged := append(p.iconToFrameTruncated, payload...)
-		payload = make([]byte, len(merged))
-		copy(payload, merged)
-		p.iconToFrameTruncated = make([]byte, 0)
-	} else if (d == common.FrameToIcon) && (len(p.frameToIconTruncated) != 0) {
-		p.logs.Printf("-> Reusing %d bytes from previously truncated packet\n", len(p.frameToIconTruncated))
-		merged := append(p.frameToIconTruncated, payload...)
-		payload = make([]byte, len(merged))
-		copy(payload, merged)
-		p.frameToIconTruncated = make([]byte, 0)
-	}
-
-	for {
-		//Not enough room for a complete block?
-		if offs+4 > len(payload) {
-			if d == common.IconToFrame {
-				p.iconToFrameTruncated = make([]byte, len(payload)-offs)
-				copy(p.iconToFrameTruncated, payload[offs:])
-				p.logs.Printf("-> Saving %d bytes of truncated data for next packet\n", len(p.iconToFrameTruncated))
-				return
-			} else if d == common.FrameToIcon {
-				p.frameToIconTruncated = make([]byte, len(payload)-offs)
-				copy(p.frameToIconTruncated, payload[offs:])
-				p.logs.Printf("-> Saving %d bytes of truncated data for next packet\n", len(p.frameToIconTruncated))
-				return
+	if !p.isIconFrameTraffic(ip) {
+		return
+	}
+
+	if len(tcp.Payload) != 0 {
+		p.logs.Println("************************************************************")
+		p.logs.Printf("[TCP Packet] RAW Payload %d bytes (0x%x) seq %d\n", len(tcp.Payload), len(tcp.Payload), tcp.Seq)
+		p.logs.Print("\n" + hex.Dump(tcp.Payload))
+	}
+
+	ts := packet.Metadata().Timestamp
+	key := p.sessionKey(ip.NetworkFlow(), tcp.TransportFlow())
+
+	//Icon is connecting, previous state for this connection is obsolete
+	if tcp.SYN && !tcp.ACK {
+		sess, found := p.sessions[key]
+		if !found || sess.synSeq != tcp.Seq {
+			if found {
+				p.endSession(sess, ts)
 			}
-			p.logs.Fatalln("Unknown TCP direction!")
-			return
+			sess = p.startSession(key, ts)
+			sess.synSeq = tcp.Seq
 		}
+	}
 
-		//version := int(binary.BigEndian.Uint16(payload[offs : offs+2]))
-		offs += 2
-		size := int(binary.BigEndian.Uint16(payload[offs : offs+2]))
-		offs += 2
-
-		//Not enough room
-		if offs+size > len(payload) {
-			offs -= 4
-			if d == common.IconToFrame {
-				p.iconToFrameTruncated = make([]byte, len(payload)-offs)
-				copy(p.iconToFrameTruncated, payload[offs:])
-				p.logs.Printf("-> Saving %d bytes of truncated data for next packet\n", len(p.iconToFrameTruncated))
-				return
-			} else if d == common.FrameToIcon {
-				p.frameToIconTruncated = make([]byte, len(payload)-offs)
-				copy(p.frameToIconTruncated, payload[offs:])
-				p.logs.Printf("-> Saving %d bytes of truncated data for next packet\n", len(p.frameToIconTruncated))
-				return
-			}
-			p.logs.Fatalln("Unknown TCP direction!")
-			return
+	p.lastPacket = ts
+	p.assembler.AssembleWithTimestamp(ip.NetworkFlow(), tcp, ts)
+
+	if tcp.RST {
+		if sess, found := p.sessions[key]; found {
+			p.endSession(sess, ts)
 		}
+	}
 
-		if size != 0 {
-			midiData := payload[offs : offs+size]
-			//p.logs.Println(hex.Dump(midiData))
-			p.midiParser.Parse(midiData, d)
-			offs += size
-		} else {
+	//Give up on segments that never came
+	if ts.Sub(p.lastFlush) > segmentTimeout {
+		p.assembler.FlushWithOptions(tcpassembly.FlushOptions{T: ts.Add(-segmentTimeout)})
+		p.lastFlush = ts
+	}
+}
+
+// Correlator returns the requests and responses pairing of all sessions
+func (p *TCPParser) Correlator() *correlator.Correlator {
+	return p.correlator
+}
+
+// Flush processes all data still waiting for missing segments
+func (p *TCPParser) Flush() {
+	p.assembler.FlushAll()
+}
+
+// New implements tcpassembly.StreamFactory
+func (p *TCPParser) New(netFlow, tcpFlow gopacket.Flow) tcpassembly.Stream {
+	s := tcpStream{parser: p, dir: common.IconToFrame}
+	if netFlow.Src().String() == p.roles.FrameIP() {
+		s.dir = common.FrameToIcon
+	}
+
+	//Capture started while the connection was already established
+	key := p.sessionKey(netFlow, tcpFlow)
+	sess, found := p.sessions[key]
+	if !found {
+		sess = p.startSession(key, p.lastPacket)
+	}
+	s.session = sess
+	return &s
+}
+
+func (s *tcpStream) Reassembled(reassemblies []tcpassembly.Reassembly) {
+	//Connection was reopened, follow the new session
+	if s.session.ended {
+		if sess, found := s.parser.sessions[s.session.key]; found {
+			s.session = sess
+		}
+	}
+
+	for _, r := range reassemblies {
+		s.session.lastSeen = r.Seen
+		if r.Skip != 0 {
+			s.parser.skip(s.session, s.dir, r.Skip, r.Seen)
+		}
+		if len(r.Bytes) != 0 {
+			s.parser.parseBlocks(s.session, r.Bytes, s.dir, r.Seen)
+		}
+	}
+}
+
+func (s *tcpStream) ReassemblyComplete() {
+	s.session.closed[s.dir] = true
+	if s.session.closed[common.IconToFrame] && s.session.closed[common.FrameToIcon] {
+		s.parser.endSession(s.session, s.session.lastSeen)
+	}
+}
+
+func (p *TCPParser) sessionKey(netFlow, tcpFlow gopacket.Flow) sessionKey {
+	if netFlow.Src().String() == p.roles.FrameIP() {
+		return sessionKey{net: netFlow.Reverse(), transport: tcpFlow.Reverse()}
+	}
+	return sessionKey{net: netFlow, transport: tcpFlow}
+}
+
+func (p *TCPParser) startSession(key sessionKey, ts time.Time) *session {
+	var sess session
+
+	p.lastSessionID++
+	sess.id = p.lastSessionID
+	sess.key = key
+	sess.decoder = decoder.New()
+	sess.lastSeen = ts
+	p.sessions[key] = &sess
+
+	p.logs.Printf("-> Session %d started (Icon %s:%s <-> Frame %s:%s)\n", sess.id,
+		key.net.Src(), key.transport.Src(), key.net.Dst(), key.transport.Dst())
+	p.emit(Event{Type: EventSessionStart, Session: sess.id, Timestamp: ts})
+	return &sess
+}
+
+func (p *TCPParser) endSession(sess *session, ts time.Time) {
+	if sess.ended {
+		return
+	}
+	sess.ended = true
+	if p.sessions[sess.key] == sess {
+		delete(p.sessions, sess.key)
+	}
+
+	p.correlator.EndSession(sess.id)
+	p.logs.Printf("-> Session %d ended\n", sess.id)
+	p.emit(Event{Type: EventSessionEnd, Session: sess.id, Timestamp: ts})
+}
+
+func (p *TCPParser) isIconFrameTraffic(ip *layers.IPv4) bool {
+	return p.roles.IsIconFrame(ip.SrcIP.String(), ip.DstIP.String())
+}
+
+func (p *TCPParser) skip(sess *session, d common.Direction, lost int, ts time.Time) {
+	if lost < 0 {
+		p.logs.Printf("-> Session %d %s stream joined while in progress, looking for next block\n", sess.id, d.String())
+	} else {
+		p.logs.Printf("[WARN] Session %d %s stream lost %d bytes, looking for next block\n", sess.id, d.String(), lost)
+	}
+
+	for _, msg := range sess.decoder.Skip(d) {
+		p.pushMessage(sess, msg, ts)
+	}
+	p.emit(Event{Type: EventGap, Session: sess.id, Direction: d, Timestamp: ts, Lost: lost})
+}
+
+func (p *TCPParser) parseBlocks(sess *session, payload []byte, d common.Direction, ts time.Time) {
+	p.logs.Printf("-> Session %d %s (tcp)\n", sess.id, d.String())
+	res := sess.decoder.Push(d, payload)
+
+	if res.Continued {
+		p.logs.Println("-> Reusing data from previously truncated packet")
+	}
+	if res.Invalid {
+		p.logs.Println("[WARN] Invalid block version, looking for next block")
+	}
+	if res.Skipped != 0 {
+		p.logs.Printf("-> Skipped %d bytes\n", res.Skipped)
+	}
+	for _, b := range res.Blocks {
+		if len(b.Data) == 0 {
 			p.logs.Println("[WARN] Empty block found")
 		}
+	}
+	for _, msg := range res.Messages {
+		p.pushMessage(sess, msg, ts)
+	}
+	if res.Pending {
+		p.logs.Println("-> Saving truncated data for next packet")
+	}
+}
 
-		//Reached the end
-		if offs == len(payload) {
-			return
+func (p *TCPParser) pushMessage(sess *session, msg decoder.Message, ts time.Time) {
+	p.midiParser.Parse(msg)
+
+	ev := Event{Type: EventMessage, Session: sess.id, Direction: msg.Direction, Timestamp: ts, Message: msg}
+	if msg.Type == decoder.MessageSysEx {
+		ev.SysEx, _ = sysex.Unmarshal(msg.Data)
+	}
+	if ev.SysEx != nil {
+		ev.Exchange = p.correlator.Push(sess.id, ts, ev.SysEx)
+		if ev.Exchange != nil {
+			p.logs.Printf("-> Session %d response to '%s' (latency %v)\n", sess.id, ev.Exchange.Request.Message, ev.Exchange.Latency)
 		}
-		msg++
+	}
+	p.emit(ev)
+}
+
+func (p *TCPParser) emit(ev Event) {
+	for _, h := range p.handlers {
+		h(ev)
 	}
 }
@@ -0,0 +1,114 @@
+package timecode
+
+import (
+	"fmt"
+	"sort"
+)
+
+// FieldStats describes the values of a field across packets
+type FieldStats struct {
+	Layout
+	Packets     int // Packets long enough to hold the field
+	Distinct    int // Number of distinct values
+	Min         uint64
+	Max         uint64
+	Increasing  float64 // Ratio of values larger than the previous one
+	CommonDelta int64   // Most common difference between consecutive values
+}
+
+// Constant returns true if the field never changes
+func (f FieldStats) Constant() bool {
+	return f.Distinct == 1
+}
+
+// Counter returns true if the field looks like a counter
+func (f FieldStats) Counter() bool {
+	return f.Increasing >= minCounterIncreases && f.CommonDelta > 0
+}
+
+func (f FieldStats) String() string {
+	endian := "BE"
+	if f.LittleEndian {
+		endian = "LE"
+	}
+	if f.Size == 1 {
+		endian = "  "
+	}
+	kind := "varying"
+	if f.Constant() {
+		kind = "constant"
+	} else if f.Counter() {
+		kind = "counter"
+	}
+	return fmt.Sprintf("offset %3d size %d %s %-8s %5d distinct, min 0x%x, max 0x%x, %3.0f%% increasing, usual delta %d",
+		f.Offset, f.Size, endian, kind, f.Distinct, f.Min, f.Max, f.Increasing*100, f.CommonDelta)
+}
+
+// Analyze computes the statistics of every byte offset, along with the 2 and 4 bytes
+// fields that look like counters, to help find the timecode fields.
+func Analyze(packets [][]byte) []FieldStats {
+	var fields []FieldStats
+
+	size := 0
+	for _, p := range packets {
+		size = max(size, len(p))
+	}
+
+	for offset := 0; offset < size; offset++ {
+		fields = append(fields, analyzeField(packets, Layout{Offset: offset, Size: 1}))
+		for _, width := range []int{2, 4} {
+			for _, le := range []bool{false, true} {
+				f := analyzeField(packets, Layout{Offset: offset, Size: width, LittleEndian: le})
+				if f.Packets != 0 && f.Counter() {
+					fields = append(fields, f)
+				}
+			}
+		}
+	}
+	return fields
+}
+
+func analyzeField(packets [][]byte, l Layout) FieldStats {
+	f := FieldStats{Layout: l}
+	values := make(map[uint64]bool)
+	deltas := make(map[int64]int)
+	increases := 0
+
+	var previous uint64
+	for _, p := range packets {
+		v, ok := l.counter(p)
+		if !ok {
+			continue
+		}
+		if f.Packets == 0 || v < f.Min {
+			f.Min = v
+		}
+		f.Max = max(f.Max, v)
+		if f.Packets != 0 {
+			if v > previous {
+				increases++
+			}
+			deltas[int64(v)-int64(previous)]++
+		}
+		values[v] = true
+		previous = v
+		f.Packets++
+	}
+
+	f.Distinct = len(values)
+	if f.Packets > 1 {
+		f.Increasing = float64(increases) / float64(f.Packets-1)
+	}
+	count := 0
+	keys := make([]int64, 0, len(deltas))
+	for d := range deltas {
+		keys = append(keys, d)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	for _, d := range keys {
+		if deltas[d] > count {
+			f.CommonDelta, count = d, deltas[d]
+		}
+	}
+	return f
+}
@@ -0,0 +1,342 @@
+package timecode
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"m6kparse/common"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+Once selected on the Icon, the Frame streams timecodes over UDP, from port 1024 to port 1027.
+The format has not been reversed yet: packets are decoded as a frame counter whose
+location is set by a Layout, the frame rate is estimated from the counter progress.
+Use Analyze on captured packets to look for the actual fields.
+
+DefaultLayout is a guess: the counter is only converted into hours, minutes, seconds
+and frames once a layout is set with SetLayout.
+*/
+
+const (
+	FramePort = 1024
+	IconPort  = 1027
+
+	gapFactor           = 3  // An interval larger than gapFactor times the average is a gap
+	minGapPackets       = 10 // Packets needed before gaps are detected
+	minRateDuration     = time.Second
+	rateTolerance       = 0.01
+	maxAnalysisPackets  = 1000
+	minCounterIncreases = 0.9 // Ratio of increasing values for a field to look like a counter
+)
+
+// Standard frame rates, estimated rates are snapped to them
+var standardRates = []float64{23.976, 24, 25, 29.97, 30, 50, 59.94, 60}
+
+// Layout locates the frame counter in a packet (not confirmed)
+type Layout struct {
+	Offset       int
+	Size         int // 1, 2, 4 or 8 bytes
+	LittleEndian bool
+}
+
+var DefaultLayout = Layout{Offset: 0, Size: 4}
+
+var ErrInvalidLayout = errors.New("invalid timecode layout")
+
+// ParseLayout reads a layout from a comma separated list of settings, the settings not
+// given are taken from DefaultLayout:
+//
+//	offset=<n>,size=<1|2|4|8>,le
+func ParseLayout(str string) (Layout, error) {
+	l := DefaultLayout
+	for _, setting := range strings.Split(str, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(setting), "=")
+		if name == "le" && value == "" {
+			l.LittleEndian = true
+			continue
+		}
+		n, err := strconv.ParseUint(value, 0, 16)
+		if err != nil {
+			return Layout{}, fmt.Errorf("%w: %q: %w", ErrInvalidLayout, setting, err)
+		}
+		switch name {
+		case "offset":
+			l.Offset = int(n)
+		case "size":
+			l.Size = int(n)
+		default:
+			return Layout{}, fmt.Errorf("%w: unknown setting %q", ErrInvalidLayout, setting)
+		}
+	}
+	if l.Size != 1 && l.Size != 2 && l.Size != 4 && l.Size != 8 {
+		return Layout{}, fmt.Errorf("%w: unsupported counter size %d", ErrInvalidLayout, l.Size)
+	}
+	return l, nil
+}
+
+func (l Layout) String() string {
+	str := fmt.Sprintf("offset=%d,size=%d", l.Offset, l.Size)
+	if l.LittleEndian {
+		str += ",le"
+	}
+	return str
+}
+
+func (l Layout) counter(data []byte) (uint64, bool) {
+	if l.Offset < 0 || l.Offset+l.Size > len(data) {
+		return 0, false
+	}
+	return readUint(data[l.Offset:l.Offset+l.Size], l.LittleEndian)
+}
+
+// Timecode is a decoded timecode packet
+type Timecode struct {
+	Stream        common.StreamKey
+	Timestamp     time.Time
+	Raw           []byte
+	Counter       uint64
+	HasCounter    bool    // Packet is too short for the layout if not set
+	FrameCount    bool    // Set if the layout was configured, the counter is taken as a frame count
+	Rate          float64 // Estimated frame rate, zero until known
+	Discontinuity bool    // Counter did not progress as usual
+}
+
+// Position converts the counter into hours, minutes, seconds and frames, using the estimated rate.
+// Only available once the layout is configured.
+func (tc Timecode) Position() (int, int, int, int, bool) {
+	fps := uint64(math.Round(tc.Rate))
+	if !tc.HasCounter || !tc.FrameCount || fps == 0 {
+		return 0, 0, 0, 0, false
+	}
+	seconds := tc.Counter / fps
+	return int(seconds / 3600), int(seconds / 60 % 60), int(seconds % 60), int(tc.Counter % fps), true
+}
+
+func (tc Timecode) String() string {
+	if !tc.HasCounter {
+		return fmt.Sprintf("raw % x", tc.Raw)
+	}
+	str := fmt.Sprintf("counter %d", tc.Counter)
+	if h, m, s, f, ok := tc.Position(); ok {
+		str = fmt.Sprintf("%02d:%02d:%02d:%02d @%gfps (%s)", h, m, s, f, tc.Rate, str)
+	}
+	if tc.Discontinuity {
+		str += " [discontinuity]"
+	}
+	return str
+}
+
+// Stats holds the statistics of a stream
+type Stats struct {
+	Stream          common.StreamKey
+	Packets         int
+	First           time.Time
+	Last            time.Time
+	MinInterval     time.Duration
+	MaxInterval     time.Duration
+	Jitter          time.Duration // Mean deviation of consecutive intervals
+	Gaps            int
+	Discontinuities int
+	Rate            float64 // Estimated frame rate, zero if unknown
+	FrameCount      bool    // Set if the layout was configured
+}
+
+// PacketRate returns the average number of packets per second
+func (s Stats) PacketRate() float64 {
+	d := s.Last.Sub(s.First).Seconds()
+	if d <= 0 {
+		return 0
+	}
+	return float64(s.Packets-1) / d
+}
+
+// MeanInterval returns the average time between two packets
+func (s Stats) MeanInterval() time.Duration {
+	if s.Packets < 2 {
+		return 0
+	}
+	return s.Last.Sub(s.First) / time.Duration(s.Packets-1)
+}
+
+func (s Stats) String() string {
+	str := fmt.Sprintf("%s: %d packets, %.1f packets/s, interval %v (min %v, max %v, jitter %v), %d gaps, %d discontinuities",
+		s.Stream, s.Packets, s.PacketRate(), s.MeanInterval(), s.MinInterval, s.MaxInterval, s.Jitter, s.Gaps, s.Discontinuities)
+	if s.Rate != 0 && s.FrameCount {
+		str += fmt.Sprintf(", %gfps", s.Rate)
+	} else if s.Rate != 0 {
+		str += fmt.Sprintf(", counter %g/s", s.Rate)
+	}
+	return str
+}
+
+type stream struct {
+	stats        Stats
+	lastInterval time.Duration
+	jitter       float64
+	firstCounter uint64
+	lastCounter  uint64
+	hasCounter   bool
+	deltas       map[int64]int
+	packets      [][]byte // Kept for analysis
+}
+
+type Handler func(tc Timecode)
+
+// Decoder decodes the timecode packets of all the streams
+type Decoder struct {
+	layout     Layout
+	configured bool
+	streams    map[common.StreamKey]*stream
+	handlers   []Handler
+}
+
+func New(layout Layout) *Decoder {
+	var d Decoder
+	d.layout = layout
+	d.streams = make(map[common.StreamKey]*stream)
+	return &d
+}
+
+// SetLayout sets the layout used to decode the packets pushed from now on,
+// the counter is then taken as a frame count
+func (d *Decoder) SetLayout(layout Layout) {
+	d.layout = layout
+	d.configured = true
+}
+
+// AddHandler registers a function called for each timecode decoded
+func (d *Decoder) AddHandler(h Handler) {
+	d.handlers = append(d.handlers, h)
+}
+
+// Push decodes a timecode packet
+func (d *Decoder) Push(key common.StreamKey, ts time.Time, data []byte) Timecode {
+	s, found := d.streams[key]
+	if !found {
+		s = &stream{stats: Stats{Stream: key, First: ts}, deltas: make(map[int64]int)}
+		d.streams[key] = s
+	}
+	tc := Timecode{Stream: key, Timestamp: ts, Raw: bytes.Clone(data), FrameCount: d.configured}
+	tc.Counter, tc.HasCounter = d.layout.counter(data)
+	s.stats.FrameCount = d.configured
+
+	s.update(&tc)
+	if len(s.packets) < maxAnalysisPackets {
+		s.packets = append(s.packets, tc.Raw)
+	}
+
+	for _, h := range d.handlers {
+		h(tc)
+	}
+	return tc
+}
+
+func (s *stream) update(tc *Timecode) {
+	st := &s.stats
+	if st.Packets != 0 {
+		interval := tc.Timestamp.Sub(st.Last)
+		if st.Packets == 1 || interval < st.MinInterval {
+			st.MinInterval = interval
+		}
+		st.MaxInterval = max(st.MaxInterval, interval)
+		if st.Packets >= minGapPackets && interval > gapFactor*st.MeanInterval() {
+			st.Gaps++
+		}
+		if st.Packets >= 2 {
+			diff := math.Abs(float64(interval - s.lastInterval))
+			s.jitter += (diff - s.jitter) / 16
+			st.Jitter = time.Duration(s.jitter)
+		}
+		s.lastInterval = interval
+	}
+	st.Packets++
+	st.Last = tc.Timestamp
+
+	if !tc.HasCounter {
+		return
+	}
+	if s.hasCounter {
+		delta := int64(tc.Counter) - int64(s.lastCounter)
+		if delta <= 0 || (len(s.deltas) != 0 && delta != s.usualDelta()) {
+			tc.Discontinuity = true
+			st.Discontinuities++
+		}
+		s.deltas[delta]++
+	} else {
+		s.firstCounter = tc.Counter
+		s.hasCounter = true
+	}
+	s.lastCounter = tc.Counter
+
+	if d := st.Last.Sub(st.First); d >= minRateDuration && s.lastCounter > s.firstCounter {
+		st.Rate = snapRate(float64(s.lastCounter-s.firstCounter) / d.Seconds())
+	}
+	tc.Rate = st.Rate
+}
+
+// usualDelta returns the most common counter progress between two packets
+func (s *stream) usualDelta() int64 {
+	var usual int64
+	count := 0
+	for delta, n := range s.deltas {
+		if n > count || (n == count && delta < usual) {
+			usual, count = delta, n
+		}
+	}
+	return usual
+}
+
+// snapRate returns the closest standard rate within tolerance, 24 and 23.976 are within 1%
+func snapRate(rate float64) float64 {
+	snapped := math.Round(rate*100) / 100
+	best := math.Inf(1)
+	for _, r := range standardRates {
+		if diff := math.Abs(rate - r); diff <= r*rateTolerance && diff < best {
+			snapped, best = r, diff
+		}
+	}
+	return snapped
+}
+
+// Stats returns the statistics of all the streams, sorted by stream
+func (d *Decoder) Stats() []Stats {
+	var stats []Stats
+	for _, s := range d.streams {
+		stats = append(stats, s.stats)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Stream.String() < stats[j].Stream.String() })
+	return stats
+}
+
+// Packets returns the first packets of a stream, kept for analysis
+func (d *Decoder) Packets(key common.StreamKey) [][]byte {
+	s, found := d.streams[key]
+	if !found {
+		return nil
+	}
+	return s.packets
+}
+
+func readUint(data []byte, littleEndian bool) (uint64, bool) {
+	var order binary.ByteOrder = binary.BigEndian
+	if littleEndian {
+		order = binary.LittleEndian
+	}
+	switch len(data) {
+	case 1:
+		return uint64(data[0]), true
+	case 2:
+		return uint64(order.Uint16(data)), true
+	case 4:
+		return uint64(order.Uint32(data)), true
+	case 8:
+		return order.Uint64(data), true
+	}
+	return 0, false
+}
@@ -0,0 +1,209 @@
+package timecode
+
+import (
+	"encoding/binary"
+	"errors"
+	"m6kparse/common"
+	"testing"
+	"time"
+)
+
+var (
+	testStream = common.StreamKey{Src: "10.0.0.9:1024", Dst: "10.0.0.2:1027"}
+	start      = time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+)
+
+// packet builds a timecode packet holding a big endian counter at offset 0
+func packet(counter uint32) []byte {
+	return binary.BigEndian.AppendUint32(nil, counter)
+}
+
+func TestRate(t *testing.T) {
+	d := New(DefaultLayout)
+	var tc Timecode
+	for i := 0; i < 50; i++ {
+		tc = d.Push(testStream, start.Add(time.Duration(i)*40*time.Millisecond), packet(uint32(1000+i)))
+		if tc.Discontinuity {
+			t.Fatalf("packet %d: unexpected discontinuity", i)
+		}
+	}
+	stats := d.Stats()
+	if len(stats) != 1 {
+		t.Fatalf("%d streams, expected 1", len(stats))
+	}
+	s := stats[0]
+	if s.Packets != 50 || s.Rate != 25 || tc.Rate != 25 || s.Gaps != 0 || s.Discontinuities != 0 || s.Jitter != 0 {
+		t.Fatalf("unexpected stats: %s", s)
+	}
+	if s.MinInterval != 40*time.Millisecond || s.MaxInterval != 40*time.Millisecond || s.MeanInterval() != 40*time.Millisecond {
+		t.Fatalf("unexpected intervals: %s", s)
+	}
+}
+
+func TestGapsAndJitter(t *testing.T) {
+	d := New(DefaultLayout)
+	ts := start
+	for i := 0; i < 100; i++ {
+		//Alternating intervals, and a lost second after 50 packets
+		interval := 30 * time.Millisecond
+		if i%2 == 0 {
+			interval = 50 * time.Millisecond
+		}
+		if i == 50 {
+			interval = time.Second
+		}
+		ts = ts.Add(interval)
+		d.Push(testStream, ts, packet(uint32(i)))
+	}
+	s := d.Stats()[0]
+	if s.Gaps != 1 {
+		t.Fatalf("%d gaps, expected 1", s.Gaps)
+	}
+	if s.Jitter < 15*time.Millisecond || s.Jitter > 30*time.Millisecond {
+		t.Fatalf("jitter %v, expected about 20ms", s.Jitter)
+	}
+	if s.MinInterval != 30*time.Millisecond || s.MaxInterval != time.Second {
+		t.Fatalf("unexpected intervals: %s", s)
+	}
+}
+
+func TestDiscontinuities(t *testing.T) {
+	d := New(DefaultLayout)
+	counters := []uint32{10, 11, 12, 13, 20, 21, 22, 5, 6, 6, 7}
+	expected := []bool{false, false, false, false, true, false, false, true, false, true, false}
+	for i, c := range counters {
+		tc := d.Push(testStream, start.Add(time.Duration(i)*40*time.Millisecond), packet(c))
+		if tc.Discontinuity != expected[i] {
+			t.Errorf("counter %d after %d: discontinuity %v", c, counters[max(i-1, 0)], tc.Discontinuity)
+		}
+	}
+	if s := d.Stats()[0]; s.Discontinuities != 3 {
+		t.Fatalf("%d discontinuities, expected 3", s.Discontinuities)
+	}
+
+	//Too short for the layout
+	if tc := d.Push(testStream, start.Add(time.Second), []byte{0x01}); tc.HasCounter || tc.Discontinuity {
+		t.Fatalf("unexpected timecode %s", tc)
+	}
+}
+
+func TestSnapRate(t *testing.T) {
+	tests := []struct {
+		rate     float64
+		expected float64
+	}{
+		{25, 25},
+		{24.9, 25},
+		{24.1, 24},
+		{24, 24},
+		{23.98, 23.976},
+		{29.96, 29.97},
+		{59.9, 59.94},
+		{26.5, 26.5},
+		{12.3456, 12.35},
+	}
+	for _, tt := range tests {
+		if r := snapRate(tt.rate); r != tt.expected {
+			t.Errorf("snapRate(%g) = %g, expected %g", tt.rate, r, tt.expected)
+		}
+	}
+}
+
+func TestPosition(t *testing.T) {
+	const counter = 25*3661 + 5
+	push := func(d *Decoder) Timecode {
+		var tc Timecode
+		for i := 0; i < 30; i++ {
+			tc = d.Push(testStream, start.Add(time.Duration(i)*40*time.Millisecond), packet(uint32(counter-29+i)))
+		}
+		return tc
+	}
+
+	//The default layout is a guess, only the raw counter is given
+	tc := push(New(DefaultLayout))
+	if _, _, _, _, ok := tc.Position(); ok || tc.String() != "counter 91530" {
+		t.Fatalf("position from the default layout: %s", tc)
+	}
+
+	d := New(DefaultLayout)
+	d.SetLayout(DefaultLayout)
+	tc = push(d)
+	h, m, s, f, ok := tc.Position()
+	if !ok || h != 1 || m != 1 || s != 1 || f != 5 {
+		t.Fatalf("unexpected position: %s", tc)
+	}
+	if tc.String() != "01:01:01:05 @25fps (counter 91530)" {
+		t.Fatalf("unexpected timecode: %s", tc)
+	}
+}
+
+func TestParseLayout(t *testing.T) {
+	tests := []struct {
+		str    string
+		layout Layout
+	}{
+		{"offset=4", Layout{Offset: 4, Size: 4}},
+		{"offset=0x10,size=2,le", Layout{Offset: 16, Size: 2, LittleEndian: true}},
+		{"size=8", Layout{Size: 8}},
+	}
+	for _, tt := range tests {
+		l, err := ParseLayout(tt.str)
+		if err != nil {
+			t.Errorf("%q: %v", tt.str, err)
+			continue
+		}
+		if l != tt.layout {
+			t.Errorf("%q: %s, expected %s", tt.str, l, tt.layout)
+		}
+		if again, err := ParseLayout(l.String()); err != nil || again != l {
+			t.Errorf("%q: %s does not parse back", tt.str, l)
+		}
+	}
+
+	for _, str := range []string{"", "offset", "size=3", "rate=25"} {
+		if _, err := ParseLayout(str); !errors.Is(err, ErrInvalidLayout) {
+			t.Errorf("%q: error %v, expected ErrInvalidLayout", str, err)
+		}
+	}
+}
+
+func TestAnalyze(t *testing.T) {
+	//Constant byte, little endian counter progressing by 37, varying byte
+	var packets [][]byte
+	for i := 0; i < 40; i++ {
+		p := []byte{0xAA}
+		p = binary.LittleEndian.AppendUint16(p, uint16(100+37*i))
+		p = append(p, byte(i*i*13))
+		packets = append(packets, p)
+	}
+	//Short packet, only the fields it holds are counted
+	packets = append(packets, []byte{0xAA})
+
+	fields := Analyze(packets)
+	find := func(l Layout) (FieldStats, bool) {
+		for _, f := range fields {
+			if f.Layout == l {
+				return f, true
+			}
+		}
+		return FieldStats{}, false
+	}
+
+	f, found := find(Layout{Offset: 0, Size: 1})
+	if !found || !f.Constant() || f.Packets != 41 || f.Min != 0xAA {
+		t.Fatalf("offset 0: %s", f)
+	}
+	f, found = find(Layout{Offset: 1, Size: 2, LittleEndian: true})
+	if !found || !f.Counter() || f.CommonDelta != 37 || f.Increasing != 1 || f.Packets != 40 || f.Min != 100 || f.Max != 100+37*39 {
+		t.Fatalf("counter at offset 1 not found: %s", f)
+	}
+	f, found = find(Layout{Offset: 3, Size: 1})
+	if !found || f.Constant() || f.Counter() {
+		t.Fatalf("offset 3: %s", f)
+	}
+	for _, f := range fields {
+		if f.Offset == 3 && f.Size != 1 {
+			t.Fatalf("field past the end of the packets: %s", f)
+		}
+	}
+}
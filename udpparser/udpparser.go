@@ -1,31 +1,41 @@
 package udpparser
 
 import (
-	"encoding/binary"
 	"encoding/hex"
+	"fmt"
 	"log"
-	"strings"
+	"m6kparse/common"
+	"m6kparse/discovery"
+	"m6kparse/meter"
+	"m6kparse/roles"
+	"m6kparse/timecode"
 
 	"github.com/google/gopacket"
 	"github.com/google/gopacket/layers"
 )
 
 type UDPParser struct {
-	iconIP  string
-	frameIP string
+	roles   *roles.Detector
 	logs    *log.Logger
+	tracker *discovery.Tracker
+	tc      *timecode.Decoder
+	meters  *meter.Decoder
+	//Ports the Icon sent a "METER PORT" message from
+	meterPorts map[layers.UDPPort]bool
 }
 
-const (
-	tcFrameDetectionMagic = 0x12345678
-)
-
-func New(iconIP string, frameIP string, logs *log.Logger) *UDPParser {
+func New(detector *roles.Detector, logs *log.Logger) *UDPParser {
 	var p UDPParser
 
 	p.logs = logs
-	p.iconIP = iconIP
-	p.frameIP = frameIP
+	p.roles = detector
+	p.tracker = discovery.NewTracker()
+	p.tracker.AddHandler(func(ev discovery.Event) {
+		p.logs.Println("[Discovery] " + ev.String())
+	})
+	p.tc = timecode.New(timecode.DefaultLayout)
+	p.meters = meter.New(meter.DefaultLayout)
+	p.meterPorts = make(map[layers.UDPPort]bool)
 
 	return &p
 }
@@ -40,72 +50,119 @@ func (p *UDPParser) Parse(packet gopacket.Packet, ip *layers.IPv4, udp *layers.U
 	p.logs.Printf("[UDP Packet] RAW Payload %d bytes (0x%d)\n", len(udp.Payload), len(udp.Payload))
 	p.logs.Print("\n" + hex.Dump(udp.Payload))
 
-	if (ip.SrcIP.String() == p.frameIP) && (ip.DstIP.String() == p.iconIP) {
+	p.tracker.Check(packet.Metadata().Timestamp)
+	src := ip.SrcIP.String()
+	dst := ip.DstIP.String()
+	iconIP := p.roles.IconIP()
+	frameIP := p.roles.FrameIP()
+
+	//Other Frames on the LAN may also answer the Icon probes
+	if iconIP != "" && (src != iconIP) && (dst == iconIP) && (src == frameIP || discovery.IsDiscovery(udp.Payload)) {
 		p.parseFrameToIconUDP(packet, ip, udp)
 		return
 	}
 
-	if (ip.SrcIP.String() == p.iconIP) && (ip.DstIP.String() == p.frameIP) {
+	if iconIP != "" && (src == iconIP) && (dst == frameIP) {
 		p.parseIconToFrameUDP(packet, ip, udp)
 		return
 	}
 
-	if (ip.SrcIP.String() == p.iconIP) && (ip.DstIP[3] == 255) {
+	if iconIP != "" && (src == iconIP) && (ip.DstIP[3] == 255) {
 		p.parseIconToBroadcastUDP(packet, ip, udp)
 		return
 	}
 	p.logs.Println("-> Unknown traffic!")
 }
 
+// Tracker returns the Mainframes assembled from the discovery responses
+func (p *UDPParser) Tracker() *discovery.Tracker {
+	return p.tracker
+}
+
+// Timecodes returns the timecode streams decoder
+func (p *UDPParser) Timecodes() *timecode.Decoder {
+	return p.tc
+}
+
+// Meters returns the meter streams decoder
+func (p *UDPParser) Meters() *meter.Decoder {
+	return p.meters
+}
+
+// Flush reports the discovery response sets still incomplete
+func (p *UDPParser) Flush() {
+	p.tracker.Flush()
+}
+
 func (p *UDPParser) parseFrameToIconUDP(packet gopacket.Packet, ip *layers.IPv4, udp *layers.UDP) {
-	magic := binary.BigEndian.Uint32(udp.Payload[0:4])
-	if magic != tcFrameDetectionMagic {
+	if !discovery.IsDiscovery(udp.Payload) {
 		//Other UDP packets are Timeframes sent from Frame to Icon.
+		if udp.SrcPort == timecode.FramePort && udp.DstPort == timecode.IconPort {
+			key := common.StreamKey{Src: fmt.Sprintf("%s:%d", ip.SrcIP, udp.SrcPort), Dst: fmt.Sprintf("%s:%d", ip.DstIP, udp.DstPort)}
+			tc := p.tc.Push(key, packet.Metadata().Timestamp, udp.Payload)
+			p.logs.Println("-> Timecode " + tc.String())
+		} else if p.meterPorts[udp.DstPort] {
+			key := common.StreamKey{Src: fmt.Sprintf("%s:%d", ip.SrcIP, udp.SrcPort), Dst: fmt.Sprintf("%s:%d", ip.DstIP, udp.DstPort)}
+			f, err := p.meters.Push(key, packet.Metadata().Timestamp, udp.Payload)
+			if err != nil {
+				p.logs.Println("[WARN] Meter: " + err.Error())
+			} else {
+				p.logs.Println("-> Meter " + f.String())
+			}
+		}
 		return
 	}
 	p.logs.Println("-> Frame to icon (udp)")
 
-	frameSerial := binary.BigEndian.Uint32(udp.Payload[4:8])
-	totalMsg := udp.Payload[8] //not sure
-	unknownA := udp.Payload[0x9:0x10]
-	currentMsg := udp.Payload[0x13] //not sure
-	fileName := string(udp.Payload[0x14:0x27])
-	fileName = strings.Trim(fileName, "\x00")
-	deviceName := string(udp.Payload[0x54:0x67])
-	deviceName = strings.Trim(deviceName, "\x00")
-
-	p.logs.Println("  Serial: ", frameSerial)
-	p.logs.Printf("  Message: %d/%d\n", currentMsg+1, totalMsg)
-	p.logs.Print("\n" + hex.Dump(unknownA))
-	p.logs.Println("  Filename: " + fileName)
-	p.logs.Println("  DeviceName: " + deviceName)
+	r, err := discovery.DecodeFrameResponse(udp.Payload)
+	if err != nil {
+		p.logs.Println("[WARN] Malformed frame response: " + err.Error())
+		return
+	}
+	p.logs.Println("  Serial: ", r.Serial)
+	p.logs.Printf("  Message: %d/%d\n", r.Index+1, r.Count)
+	p.logs.Print("\n" + hex.Dump(r.UnknownA))
+	p.logs.Println("  Filename: " + r.EntryName)
+	p.logs.Println("  DeviceName: " + r.DeviceName)
+
+	p.tracker.Push(ip.SrcIP.String(), packet.Metadata().Timestamp, r)
 }
 
 func (p *UDPParser) parseIconToFrameUDP(packet gopacket.Packet, ip *layers.IPv4, udp *layers.UDP) {
-	magic := binary.BigEndian.Uint32(udp.Payload[0:4])
-	if magic != tcFrameDetectionMagic {
+	if !discovery.IsDiscovery(udp.Payload) {
 		return
 	}
 
 	p.logs.Println("-> Icon to frame")
-	command := string(udp.Payload[4:15])
-	command = strings.Trim(command, "\x00")
+	m, err := discovery.Decode(udp.Payload)
+	if err != nil {
+		p.logs.Println("[WARN] Malformed icon command: " + err.Error())
+		return
+	}
 
-	p.logs.Println("-> Icon command " + command)
+	p.logs.Println("-> Icon command " + m.String())
+	if _, ok := m.(*discovery.MeterPort); ok {
+		p.meterPorts[udp.SrcPort] = true
+	}
 }
 
 func (p *UDPParser) parseIconToBroadcastUDP(packet gopacket.Packet, ip *layers.IPv4, udp *layers.UDP) {
 
 	p.logs.Println("-> Icon broadcast")
-	magic := binary.BigEndian.Uint32(udp.Payload[0:4])
-	if magic != tcFrameDetectionMagic {
-		p.logs.Printf("-> Invalid TC Magic: 0x%08x\n", magic)
+	m, err := discovery.Decode(udp.Payload)
+	if err != nil {
+		p.logs.Println("-> Invalid discovery message: " + err.Error())
 		return
 	}
-	name := string(udp.Payload[4:16])
-	name = strings.Trim(name, "\x00")
-	p.logs.Println("Icon detect probe:")
-	p.logs.Printf("  Magic %08x\n", magic)
-	p.logs.Println("  Name: " + name)
-	p.logs.Print("\n" + hex.Dump(udp.Payload[16:]))
+	switch m := m.(type) {
+	case *discovery.Probe:
+		p.logs.Println("Icon detect probe:")
+		p.logs.Println("  Name: " + m.Name)
+		p.logs.Print("\n" + hex.Dump(m.Unknown))
+	case *discovery.MeterPort:
+		p.logs.Println("-> " + m.String())
+		p.meterPorts[udp.SrcPort] = true
+	default:
+		p.logs.Println("-> " + m.String())
+	}
 }